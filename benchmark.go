@@ -0,0 +1,196 @@
+// Copyright (c) 2023, Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httpapitest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Benchmark is a benchmarking helper function that makes the same request as
+// the Request function b.N times. Options are applied and the request is
+// built only once. The first request is validated with all options before the
+// benchmark timer is reset, while in the benchmark loop only the response
+// status code is validated and the response body is discarded. The average
+// size of response bodies is reported as the resp-B/op metric.
+func Benchmark(b *testing.B, client *http.Client, method, url string, opts ...Option) {
+	b.Helper()
+
+	bench := newBenchmark(b, client, method, url, opts)
+
+	var size int64
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		req := *bench.request
+		if bench.body != nil {
+			req.Body = io.NopCloser(bytes.NewReader(bench.body))
+		}
+		resp, err := client.Do(&req)
+		if err != nil {
+			b.Fatal(err)
+		}
+		n, err := io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			b.Fatal(err)
+		}
+		size += n
+		bench.validateStatus(resp.StatusCode)
+	}
+	b.StopTimer()
+	b.ReportMetric(float64(size)/float64(b.N), "resp-B/op")
+}
+
+// BenchmarkHandler is a benchmarking helper function that serves the same
+// request by the handler b.N times, without a network connection, in the same
+// way as the Benchmark function.
+func BenchmarkHandler(b *testing.B, handler http.Handler, method, url string, opts ...Option) {
+	b.Helper()
+
+	bench := newBenchmark(b, &http.Client{Transport: handlerTransport{handler: handler}}, method, url, opts)
+	template := serverRequest(bench.request)
+
+	w := &benchmarkResponseWriter{header: make(http.Header)}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// handler may replace the body or parse the form of the request, so
+		// every iteration serves a copy of it
+		req := *template
+		if bench.body != nil {
+			req.Body = io.NopCloser(bytes.NewReader(bench.body))
+		}
+		w.reset()
+		handler.ServeHTTP(w, &req)
+		bench.validateStatus(w.status)
+	}
+	b.StopTimer()
+	b.ReportMetric(float64(w.size)/float64(b.N), "resp-B/op")
+}
+
+type benchmark struct {
+	b            *testing.B
+	request      *http.Request
+	body         []byte
+	responseCode int
+}
+
+// newBenchmark validates the first request with all options and builds the
+// request that is made in the benchmark loop.
+func newBenchmark(b *testing.B, client *http.Client, method, url string, opts []Option) *benchmark {
+	b.Helper()
+
+	o, err := newOptions(opts)
+	if err != nil {
+		b.Fatal(err)
+	}
+	next, err := o.replayable()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	next().request(b, client, method, url)
+
+	o = next()
+	var body []byte
+	if o.requestBody != nil {
+		body, err = io.ReadAll(o.requestBody)
+		if err != nil {
+			b.Fatal(err)
+		}
+		o.requestBody = bytes.NewReader(body)
+	}
+	req, requestBodyData, err := o.newRequest(method, url)
+	if err != nil {
+		b.Fatal(err)
+	}
+	if requestBodyData != nil {
+		body = requestBodyData
+	}
+	req.Body = nil
+	req.GetBody = nil
+	return &benchmark{
+		b:            b,
+		request:      req,
+		body:         body,
+		responseCode: o.responseCode,
+	}
+}
+
+func (bench *benchmark) validateStatus(code int) {
+	if bench.responseCode != 0 && code != bench.responseCode {
+		bench.b.Fatalf("got response status %v %s, want %v %s", code, http.StatusText(code), bench.responseCode, http.StatusText(bench.responseCode))
+	}
+}
+
+// handlerTransport is an http.RoundTripper that serves requests by the handler
+// directly.
+type handlerTransport struct {
+	handler http.Handler
+}
+
+func (t handlerTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	w := httptest.NewRecorder()
+	t.handler.ServeHTTP(w, serverRequest(r))
+	resp := w.Result()
+	resp.Request = r
+	return resp, nil
+}
+
+// serverRequest returns a copy of the client request with fields that are set
+// by the server for incoming requests.
+func serverRequest(r *http.Request) *http.Request {
+	r = r.Clone(r.Context())
+	r.RequestURI = r.URL.RequestURI()
+	r.RemoteAddr = "192.0.2.1:1234"
+	if r.Body == nil {
+		r.Body = http.NoBody
+	}
+	return r
+}
+
+// benchmarkResponseWriter is an http.ResponseWriter that counts the size of
+// the response body without storing it.
+type benchmarkResponseWriter struct {
+	header      http.Header
+	status      int
+	wroteHeader bool
+	size        int64
+}
+
+func (w *benchmarkResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *benchmarkResponseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	if code < 100 || code > 999 {
+		panic(fmt.Sprintf("invalid WriteHeader code %v", code))
+	}
+	w.status = code
+	w.wroteHeader = true
+}
+
+func (w *benchmarkResponseWriter) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	w.size += int64(len(p))
+	return len(p), nil
+}
+
+func (w *benchmarkResponseWriter) reset() {
+	for k := range w.header {
+		delete(w.header, k)
+	}
+	w.status = http.StatusOK
+	w.wroteHeader = false
+}
@@ -0,0 +1,137 @@
+// Copyright (c) 2023, Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httpapitest_test
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"resenje.org/httpapitest"
+)
+
+func TestBenchmark(t *testing.T) {
+
+	// only correctness is tested, with a small fixed number of iterations
+	// instead of running each benchmark for a second
+	setBenchtime(t, "10x")
+
+	var calls int32
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if b, _ := io.ReadAll(r.Body); string(b) != `{"name":"bench"}` {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"ok":true}`)
+	})
+
+	// benchmark function is called multiple times and the request body reader
+	// is consumed every time
+	opts := func() []httpapitest.Option {
+		return []httpapitest.Option{
+			httpapitest.WithRequestBody(strings.NewReader(`{"name":"{{name}}"}`)),
+			httpapitest.WithVars(varsWith("name", "bench")),
+			httpapitest.ExpectStatus(http.StatusOK),
+			httpapitest.ExpectJSONPath("$.ok", true),
+		}
+	}
+
+	t.Run("client", func(t *testing.T) {
+		c, endpoint := newClient(t, handler)
+		atomic.StoreInt32(&calls, 0)
+		r := testing.Benchmark(func(b *testing.B) {
+			httpapitest.Benchmark(b, c, http.MethodPost, endpoint, opts()...)
+		})
+		if r.N == 0 {
+			t.Fatal("benchmark failed")
+		}
+		if got := r.Extra["resp-B/op"]; got != 11 {
+			t.Errorf("got %v resp-B/op, want 11", got)
+		}
+		if calls <= int32(r.N) {
+			t.Errorf("got %v calls for %v iterations", calls, r.N)
+		}
+	})
+
+	t.Run("handler", func(t *testing.T) {
+		r := testing.Benchmark(func(b *testing.B) {
+			httpapitest.BenchmarkHandler(b, handler, http.MethodPost, "http://localhost/", opts()...)
+		})
+		if r.N == 0 {
+			t.Fatal("benchmark failed")
+		}
+		if got := r.Extra["resp-B/op"]; got != 11 {
+			t.Errorf("got %v resp-B/op, want 11", got)
+		}
+	})
+
+	t.Run("handler modifies request", func(t *testing.T) {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Form != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, 16)
+			if err := r.ParseForm(); err != nil || r.PostForm.Get("name") != "bench" {
+				w.WriteHeader(http.StatusBadRequest)
+			}
+		})
+		r := testing.Benchmark(func(b *testing.B) {
+			httpapitest.BenchmarkHandler(b, handler, http.MethodPost, "http://localhost/",
+				httpapitest.WithRequestBody(strings.NewReader("name=bench")),
+				httpapitest.WithRequestHeader("Content-Type", "application/x-www-form-urlencoded"),
+				httpapitest.ExpectStatus(http.StatusOK),
+			)
+		})
+		if r.N == 0 {
+			t.Fatal("benchmark failed")
+		}
+	})
+
+	t.Run("status", func(t *testing.T) {
+		r := testing.Benchmark(func(b *testing.B) {
+			httpapitest.BenchmarkHandler(b, handler, http.MethodPost, "http://localhost/",
+				httpapitest.WithRequestBody(strings.NewReader("invalid")),
+				httpapitest.ExpectStatus(http.StatusOK),
+			)
+		})
+		if r.N != 0 {
+			t.Error("benchmark did not fail")
+		}
+	})
+}
+
+// setBenchtime sets the value of the -test.benchtime flag that is used by the
+// testing.Benchmark function for the duration of the test.
+func setBenchtime(t *testing.T, value string) {
+	t.Helper()
+
+	f := flag.Lookup("test.benchtime")
+	if f == nil {
+		t.Fatal("test.benchtime flag is not defined")
+	}
+	previous := f.Value.String()
+	if err := f.Value.Set(value); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := f.Value.Set(previous); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func varsWith(name, value string) *httpapitest.Vars {
+	v := new(httpapitest.Vars)
+	v.Set(name, value)
+	return v
+}
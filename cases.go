@@ -0,0 +1,76 @@
+// Copyright (c) 2023, Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httpapitest
+
+import (
+	"net/http"
+	"testing"
+)
+
+// Case describes a single request made by the Cases function.
+type Case struct {
+	// Name is the subtest name. If it is empty, method and URL are used.
+	Name string
+	// Method is the HTTP request method. If it is empty, GET is used.
+	Method string
+	// URL is the request URL.
+	URL string
+	// Options are applied to the request after the ones provided to the
+	// Cases function.
+	Options []Option
+	// Parallel marks the subtest to be run in parallel with other parallel
+	// subtests.
+	Parallel bool
+}
+
+func (c Case) method() string {
+	if c.Method == "" {
+		return http.MethodGet
+	}
+	return c.Method
+}
+
+func (c Case) name() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return c.method() + " " + c.URL
+}
+
+// Cases runs every test case as a subtest that makes a request with the
+// Request function using the provided client. The provided options are applied
+// to every request before the ones defined in the test case.
+//
+// Example:
+//
+//	httpapitest.Cases(t, client, []httpapitest.Case{
+//		{
+//			Name:    "get user",
+//			URL:     url + "/users/1",
+//			Options: []httpapitest.Option{httpapitest.ExpectStatus(http.StatusOK)},
+//		},
+//		{
+//			Name:     "missing user",
+//			URL:      url + "/users/0",
+//			Options:  []httpapitest.Option{httpapitest.ExpectStatus(http.StatusNotFound)},
+//			Parallel: true,
+//		},
+//	})
+func Cases(t *testing.T, client *http.Client, cases []Case, opts ...Option) {
+	t.Helper()
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name(), func(t *testing.T) {
+			t.Helper()
+
+			if c.Parallel {
+				t.Parallel()
+			}
+			Request(t, client, c.method(), c.URL, append(opts[:len(opts):len(opts)], c.Options...)...)
+		})
+	}
+}
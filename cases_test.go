@@ -0,0 +1,63 @@
+// Copyright (c) 2023, Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httpapitest_test
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+
+	"resenje.org/httpapitest"
+)
+
+func TestCases(t *testing.T) {
+
+	var mu sync.Mutex
+	var got []string
+	c, endpoint := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		got = append(got, r.Method+" "+r.URL.Path+" "+r.Header.Get("X-Default"))
+		mu.Unlock()
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	t.Run("cases", func(t *testing.T) {
+		httpapitest.Cases(t, c, []httpapitest.Case{
+			{
+				Name: "get",
+				URL:  endpoint + "/users",
+			},
+			{
+				Method:   http.MethodDelete,
+				URL:      endpoint + "/users",
+				Parallel: true,
+			},
+			{
+				Name:     "missing",
+				URL:      endpoint + "/missing",
+				Options:  []httpapitest.Option{httpapitest.ExpectStatus(http.StatusNotFound)},
+				Parallel: true,
+			},
+		},
+			httpapitest.WithRequestHeader("X-Default", "value"),
+			httpapitest.ExpectStatus(http.StatusOK),
+		)
+	})
+
+	sort.Strings(got)
+	want := []string{
+		"DELETE /users value",
+		"GET /missing value",
+		"GET /users value",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got requests %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,526 @@
+// Copyright (c) 2023, Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httpapitest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"time"
+)
+
+// Kinds of assertion errors.
+const (
+	AssertionStatus     = "status"
+	AssertionHeader     = "header"
+	AssertionJSONSchema = "json schema"
+	AssertionOpenAPI    = "openapi"
+	AssertionJSON       = "json"
+	AssertionBody       = "body"
+	AssertionCapture    = "capture"
+	AssertionLatency    = "latency"
+	AssertionEvent      = "event"
+)
+
+// AssertionError describes a single failed validation of the response.
+type AssertionError struct {
+	// Kind is one of the Assertion constants.
+	Kind string
+	// Path is the header name, JSONPath expression, JSON pointer or the
+	// response body position of the validated value, if applicable.
+	Path string
+	// Expected is the expected value, if applicable.
+	Expected string
+	// Actual is the value in the response, if applicable.
+	Actual string
+	// Message is the description reported by the Request function.
+	Message string
+}
+
+func (e AssertionError) Error() string {
+	return e.Message
+}
+
+// Check makes an HTTP request in the same way as the Request function, with
+// the same options, but instead of reporting failures to testing.TB it returns
+// all failed validations. A non-nil error is returned if the request could not
+// be made or the response could not be processed, when the validations after
+// the failure are not performed. An error is also returned if any of the
+// options failed, and it is recorded in the report set by the WithReport
+// option. Options that log on failure, WithDumpOnFailure and
+// WithCurlOnFailure, have no effect.
+func Check(client *http.Client, method, url string, opts ...Option) ([]AssertionError, error) {
+	start := time.Now()
+	o, err := newOptions(opts)
+	if err != nil {
+		// the request is not made, but it must not be missing from the report
+		o.reportResult("", method, url, start, &result{err: err})
+		return nil, err
+	}
+	r := o.do(client, method, url)
+	o.reportResult("", method, url, start, r)
+	return r.failures, r.err
+}
+
+// newOptions applies all options and returns the first error, if any of them
+// failed. Returned options are never nil.
+func newOptions(opts []Option) (*options, error) {
+	o := new(options)
+	var firstErr error
+	for _, opt := range opts {
+		if err := opt.apply(o); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr != nil {
+		return o, firstErr
+	}
+	if o.captures != nil && o.vars == nil {
+		return o, errMissingVars
+	}
+	if err := o.responseBodyStreamed(); err != nil {
+		return o, err
+	}
+	return o, nil
+}
+
+// responseBodyStreamed returns an error if the response body is read as a
+// stream by an option and any other option requires the whole response body.
+func (o *options) responseBodyStreamed() error {
+	var stream string
+	switch {
+	case o.events != nil:
+		stream = "ExpectEvents"
+	case o.jsonLines != nil:
+		stream = "ExpectJSONLines"
+	default:
+		return nil
+	}
+	var name string
+	switch {
+	case o.events != nil && o.jsonLines != nil:
+		name = "ExpectJSONLines"
+	case o.jsonSchema != nil:
+		name = "ExpectJSONSchema"
+	case o.jsonPaths != nil:
+		name = "ExpectJSONPath"
+	case o.jsonSubsets != nil:
+		name = "ExpectJSONSubset"
+	case o.capturesJSONPath():
+		name = "CaptureJSONPath"
+	case o.expectedResponse != nil:
+		name = "ExpectedResponse"
+	case o.expectedJSONResponse != nil:
+		name = "ExpectedJSONResponse"
+	case o.unmarshalResponse != nil:
+		name = "UnmarshalJSONResponse"
+	case o.responseBody != nil:
+		name = "PutResponseBody"
+	case o.noResponseBody:
+		name = "ExpectNoResponseBody"
+	default:
+		return nil
+	}
+	return fmt.Errorf("response body is streamed by the %s option and can not be used by the %s option", stream, name)
+}
+
+// replayable returns a function that returns copies of options for
+// repeated requests. Request body and expected response readers are consumed
+// once and every copy gets its own readers of their data.
+func (o *options) replayable() (func() *options, error) {
+	var requestBody, expectedResponse []byte
+	if o.requestBody != nil {
+		b, err := io.ReadAll(o.requestBody)
+		if err != nil {
+			return nil, err
+		}
+		requestBody = b
+	}
+	if o.expectedResponse != nil {
+		b, err := io.ReadAll(o.expectedResponse)
+		if err != nil {
+			return nil, err
+		}
+		expectedResponse = b
+	}
+	return func() *options {
+		c := *o
+		if requestBody != nil {
+			c.requestBody = bytes.NewReader(requestBody)
+		}
+		if expectedResponse != nil {
+			c.expectedResponse = bytes.NewReader(expectedResponse)
+		}
+		return &c
+	}, nil
+}
+
+// result holds the outcome of a request and its validations.
+type result struct {
+	failures []AssertionError
+	err      error
+	exchange exchange
+	attempts int
+	duration time.Duration
+}
+
+func (r *result) failed() bool {
+	return len(r.failures) > 0 || r.err != nil
+}
+
+// do makes the request once or, with the Eventually option, until it passes.
+func (o *options) do(client *http.Client, method, url string) *result {
+	if o.eventually != nil {
+		return o.eventually.do(client, method, url, o)
+	}
+	r := &result{attempts: 1}
+	start := time.Now()
+	r.failures, r.err = o.check(client, method, url, &r.exchange)
+	r.duration = time.Since(start)
+	return r
+}
+
+// check makes the request and validates the response, recording them in the
+// exchange.
+func (o *options) check(client *http.Client, method, url string, x *exchange) (failures []AssertionError, err error) {
+	fail := func(e AssertionError) {
+		failures = append(failures, e)
+	}
+
+	req, requestBodyData, err := o.newRequest(method, url)
+	if err != nil {
+		return failures, err
+	}
+	if o.timeout > 0 {
+		ctx, cancel := context.WithTimeout(req.Context(), o.timeout)
+		defer cancel()
+		req = req.WithContext(ctx)
+	}
+	ctx := req.Context()
+	var start time.Time
+	var tracer *timingTracer
+	x.requestBody = requestBodyData
+	defer func() {
+		x.timing = tracer.result()
+		if o.timing != nil {
+			*o.timing = x.timing
+		}
+	}()
+
+	var resp *http.Response
+	for attempt := 1; ; attempt++ {
+		start = time.Now()
+		tracer = newTimingTracer(start)
+		req = req.WithContext(httptrace.WithClientTrace(ctx, tracer.clientTrace()))
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return failures, err
+			}
+			req.Body = body
+		}
+		x.request = req
+
+		resp, err = client.Do(req)
+		wait, retry := o.retry.wait(ctx, attempt, resp, err)
+		if !retry {
+			break
+		}
+		x.retries = append(x.retries, retryMessage(attempt, resp, err, wait))
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return failures, ctx.Err()
+		}
+	}
+	if err != nil {
+		return failures, err
+	}
+	defer resp.Body.Close()
+	wait := time.Since(start)
+	tracer.gotHeaders()
+	resp.Body = tracer.body(resp.Body)
+
+	var body io.Reader = resp.Body
+	var responseBodyData []byte
+	if o.keepResponseBody() && o.events == nil && o.jsonLines == nil {
+		b, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return failures, err
+		}
+		responseBodyData = b
+		body = bytes.NewReader(b)
+	}
+	total := time.Since(start)
+	x.response = resp
+	x.responseBody = responseBodyData
+
+	// streamed response bodies are recorded when they are read
+	record := func(body []byte) {
+		if o.har != nil {
+			o.har.record(start, wait, time.Since(start), req, requestBodyData, resp, body)
+		}
+	}
+	if o.events == nil && o.jsonLines == nil {
+		record(responseBodyData)
+	}
+
+	if ttfb := tracer.result().TimeToFirstByte; o.maxTimeToFirstByte > 0 && ttfb > o.maxTimeToFirstByte {
+		fail(AssertionError{
+			Kind:     AssertionLatency,
+			Path:     "time to first byte",
+			Expected: o.maxTimeToFirstByte.String(),
+			Actual:   ttfb.String(),
+			Message:  fmt.Sprintf("got response time to first byte %v, want at most %v", ttfb, o.maxTimeToFirstByte),
+		})
+	}
+	if o.maxLatency > 0 && total > o.maxLatency {
+		fail(AssertionError{
+			Kind:     AssertionLatency,
+			Path:     "total",
+			Expected: o.maxLatency.String(),
+			Actual:   total.String(),
+			Message:  fmt.Sprintf("got response time %v, want at most %v", total, o.maxLatency),
+		})
+	}
+
+	if o.responseCode != 0 {
+		if resp.StatusCode != o.responseCode {
+			want := fmt.Sprintf("%v %s", o.responseCode, http.StatusText(o.responseCode))
+			fail(AssertionError{
+				Kind:     AssertionStatus,
+				Expected: want,
+				Actual:   resp.Status,
+				Message:  fmt.Sprintf("got response status %s, want %s", resp.Status, want),
+			})
+		}
+	}
+
+	for key := range o.responseHeaders {
+		want := o.responseHeaders.Get(key)
+		got := resp.Header.Get(key)
+		if got != want {
+			fail(AssertionError{
+				Kind:     AssertionHeader,
+				Path:     key,
+				Expected: want,
+				Actual:   got,
+				Message:  fmt.Sprintf("got header %q value %q, want %q", key, got, want),
+			})
+		}
+	}
+
+	if o.jsonSchema != nil {
+		violations, err := o.jsonSchema.validateJSON(responseBodyData)
+		if err != nil {
+			fail(AssertionError{
+				Kind:    AssertionJSONSchema,
+				Actual:  string(responseBodyData),
+				Message: fmt.Sprintf("got invalid json response %q: %v", string(responseBodyData), err),
+			})
+		}
+		for _, v := range violations {
+			fail(AssertionError{
+				Kind:     AssertionJSONSchema,
+				Path:     v.instanceLocation,
+				Expected: v.keywordLocation,
+				Message:  v.Error(),
+			})
+		}
+	}
+
+	if o.openAPI != nil {
+		for _, err := range o.openAPI.validate(req, requestBodyData, resp, responseBodyData, o.events != nil || o.jsonLines != nil) {
+			fail(AssertionError{
+				Kind:    AssertionOpenAPI,
+				Message: err.Error(),
+			})
+		}
+	}
+
+	if o.jsonPaths != nil || o.jsonSubsets != nil {
+		failures = append(failures, o.validateJSONExpectations(responseBodyData)...)
+	}
+
+	failures = append(failures, o.captureVars(resp, responseBodyData)...)
+
+	if o.events != nil {
+		data, f, err := o.events.validate(resp)
+		x.responseBody = data
+		record(data)
+		return append(failures, f...), err
+	}
+
+	if o.jsonLines != nil {
+		var data *bytes.Buffer
+		if o.har != nil || o.dump != nil {
+			data = new(bytes.Buffer)
+			body = io.TeeReader(body, data)
+		}
+		f, err := o.jsonLines.validate(body)
+		if data != nil {
+			x.responseBody = data.Bytes()
+			record(x.responseBody)
+		}
+		return append(failures, f...), err
+	}
+
+	if o.expectedResponse != nil {
+		e, err := readerContentEqual(body, o.expectedResponse)
+		if e != nil {
+			fail(*e)
+		}
+		return failures, err
+	}
+
+	if o.expectedJSONResponse != nil {
+		got, err := io.ReadAll(body)
+		if err != nil {
+			return failures, err
+		}
+		got = bytes.TrimSpace(got)
+
+		want, err := json.Marshal(o.expectedJSONResponse)
+		if err != nil {
+			return failures, err
+		}
+
+		if !bytes.Equal(got, want) {
+			fail(AssertionError{
+				Kind:     AssertionBody,
+				Expected: string(want),
+				Actual:   string(got),
+				Message:  fmt.Sprintf("got json response %q, want %q", string(got), string(want)),
+			})
+		}
+		return failures, nil
+	}
+
+	if o.unmarshalResponse != nil {
+		dec := json.NewDecoder(body)
+		if o.strictJSON {
+			dec.DisallowUnknownFields()
+		}
+		if err := dec.Decode(o.unmarshalResponse); err != nil {
+			return failures, err
+		}
+		if o.strictJSON {
+			if _, err := dec.Token(); err != io.EOF {
+				return failures, errors.New("unexpected data after json response value")
+			}
+		}
+		return failures, nil
+	}
+
+	if o.responseBody != nil {
+		got, err := io.ReadAll(body)
+		if err != nil {
+			return failures, err
+		}
+		*o.responseBody = got
+		return failures, nil
+	}
+
+	if o.noResponseBody {
+		got, err := io.ReadAll(body)
+		if err != nil {
+			return failures, err
+		}
+		if len(got) > 0 {
+			fail(AssertionError{
+				Kind:    AssertionBody,
+				Actual:  string(got),
+				Message: fmt.Sprintf("got response body %q, want none", string(got)),
+			})
+		}
+	}
+	return failures, nil
+}
+
+// newRequest creates the request with the body, headers and context set by
+// options, with interpolated variables. It returns the request body data if it
+// is kept by options.
+func (o *options) newRequest(method, url string) (*http.Request, []byte, error) {
+	requestBody := o.requestBody
+	var requestBodyData []byte
+	if o.keepRequestBody() && requestBody != nil {
+		b, err := io.ReadAll(requestBody)
+		if err != nil {
+			return nil, nil, err
+		}
+		requestBodyData = b
+		requestBody = bytes.NewReader(b)
+	}
+
+	requestHeaders := o.requestHeaders
+	if o.vars != nil {
+		var err error
+		url, requestHeaders, requestBodyData, err = o.expandRequest(url, requestBodyData)
+		if err != nil {
+			return nil, nil, err
+		}
+		if requestBodyData != nil {
+			requestBody = bytes.NewReader(requestBodyData)
+		}
+	}
+
+	req, err := http.NewRequest(method, url, requestBody)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header = requestHeaders
+	if o.ctx != nil {
+		req = req.WithContext(o.ctx)
+	}
+	return req, requestBodyData, nil
+}
+
+func readerContentEqual(r1, r2 io.Reader) (*AssertionError, error) {
+	const bufSize = 128
+
+	buf1 := make([]byte, bufSize)
+	buf2 := make([]byte, bufSize)
+
+	var cursor int
+	for {
+		n1, err := r1.Read(buf1)
+		buf1 = buf1[:n1]
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("read input data at position %v: %v", cursor, err)
+		}
+		n2, err := r2.Read(buf2)
+		buf2 = buf2[:n2]
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("read validation data at position %v: %v", cursor, err)
+		}
+
+		if !bytes.Equal(buf1, buf2) {
+			return &AssertionError{
+				Kind:     AssertionBody,
+				Path:     fmt.Sprint(cursor),
+				Expected: string(buf2),
+				Actual:   string(buf1),
+				Message:  fmt.Sprintf("data not equal at position %v: got %q, want %q", cursor, string(buf1), string(buf2)),
+			}, nil
+		}
+
+		if err == io.EOF {
+			break
+		}
+
+		cursor += n1
+	}
+	return nil, nil
+}
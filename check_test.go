@@ -0,0 +1,112 @@
+// Copyright (c) 2023, Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httpapitest_test
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"resenje.org/httpapitest"
+)
+
+func TestCheck(t *testing.T) {
+
+	c, endpoint := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprint(w, `{"id":1,"roles":["admin"]}`)
+	}))
+
+	failures, err := httpapitest.Check(c, http.MethodGet, endpoint,
+		httpapitest.ExpectStatus(http.StatusOK),
+		httpapitest.ExpectResponseHeader("Content-Type", "text/plain"),
+		httpapitest.ExpectJSONPath("$.id", 2),
+		httpapitest.ExpectJSONPath("$.name", "test"),
+		httpapitest.ExpectJSONSubset(map[string]interface{}{"roles": []string{"admin", "user"}}),
+		httpapitest.ExpectNoResponseBody(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []httpapitest.AssertionError{
+		{
+			Kind:     httpapitest.AssertionStatus,
+			Expected: "200 OK",
+			Actual:   "202 Accepted",
+			Message:  "got response status 202 Accepted, want 200 OK",
+		},
+		{
+			Kind:     httpapitest.AssertionHeader,
+			Path:     "Content-Type",
+			Expected: "text/plain",
+			Actual:   "application/json",
+			Message:  `got header "Content-Type" value "application/json", want "text/plain"`,
+		},
+		{
+			Kind:     httpapitest.AssertionJSON,
+			Path:     "$.id",
+			Expected: "2",
+			Actual:   "1",
+			Message:  `got json value at "$.id" 1, want 2`,
+		},
+		{
+			Kind:     httpapitest.AssertionJSON,
+			Path:     "$.name",
+			Expected: `"test"`,
+			Message:  `got no json value at "$.name", want "test"`,
+		},
+		{
+			Kind:     httpapitest.AssertionJSON,
+			Path:     "$.roles",
+			Expected: `["admin","user"]`,
+			Actual:   `["admin"]`,
+			Message:  `got json array at "$.roles" with 1 elements, want 2`,
+		},
+		{
+			Kind:    httpapitest.AssertionBody,
+			Actual:  `{"id":1,"roles":["admin"]}`,
+			Message: `got response body "{\"id\":1,\"roles\":[\"admin\"]}", want none`,
+		},
+	}
+	if !reflect.DeepEqual(failures, want) {
+		t.Errorf("got failures %+v, want %+v", failures, want)
+	}
+
+	failures, err = httpapitest.Check(c, http.MethodGet, endpoint,
+		httpapitest.ExpectStatus(http.StatusAccepted),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failures != nil {
+		t.Errorf("got failures %+v", failures)
+	}
+}
+
+func TestCheck_error(t *testing.T) {
+
+	_, err := httpapitest.Check(http.DefaultClient, http.MethodGet, "http://localhost",
+		httpapitest.CaptureHeader("id", "X-Id"),
+	)
+	if err == nil || err.Error() != "capture variables: missing WithVars option" {
+		t.Errorf("got error %v", err)
+	}
+
+	c, endpoint := newClient(t, http.NotFoundHandler())
+	endpoint = strings.Replace(endpoint, "http://", "unknown://", 1)
+	failures, err := httpapitest.Check(c, http.MethodGet, endpoint,
+		httpapitest.ExpectStatus(http.StatusOK),
+	)
+	if err == nil || !strings.Contains(err.Error(), "unsupported protocol scheme") {
+		t.Errorf("got error %v", err)
+	}
+	if failures != nil {
+		t.Errorf("got failures %+v", failures)
+	}
+}
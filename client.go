@@ -0,0 +1,48 @@
+// Copyright (c) 2023, Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httpapitest
+
+import (
+	"net/http"
+	"testing"
+)
+
+// Client makes requests with the Request function using the same HTTP client
+// and options that are applied to every request, such as WithOpenAPI.
+type Client struct {
+	HTTPClient *http.Client
+	Options    []Option
+}
+
+// NewClient returns a new Client that uses the provided HTTP client and default
+// options. If the HTTP client is nil, http.DefaultClient is used.
+func NewClient(client *http.Client, opts ...Option) *Client {
+	return &Client{
+		HTTPClient: client,
+		Options:    opts,
+	}
+}
+
+// Request calls the Request function with the client's HTTP client. Client's
+// default options are applied before the provided options.
+func (c *Client) Request(t testing.TB, method, url string, opts ...Option) {
+	t.Helper()
+
+	Request(t, c.httpClient(), method, url, c.options(opts)...)
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient == nil {
+		return http.DefaultClient
+	}
+	return c.HTTPClient
+}
+
+func (c *Client) options(opts []Option) []Option {
+	o := make([]Option, 0, len(c.Options)+len(opts))
+	o = append(o, c.Options...)
+	return append(o, opts...)
+}
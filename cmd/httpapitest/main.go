@@ -0,0 +1,166 @@
+// Copyright (c) 2023, Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Command httpapitest runs declarative test cases from JSON and .http files
+// against a live HTTP server and writes results in TAP, JUnit XML or JSON
+// format.
+//
+// Usage:
+//
+//	httpapitest -base-url https://api.example.com [flags] path...
+//
+// Every path is a test case file or a directory with test case files, in the
+// format described by the RunFiles function of the resenje.org/httpapitest
+// package. The command exits with status 1 if any test case fails.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"resenje.org/httpapitest"
+)
+
+func main() {
+	ok, err := run(os.Args[1:], os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "httpapitest:", err)
+		os.Exit(2)
+	}
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+type stringsFlag []string
+
+func (f *stringsFlag) String() string { return strings.Join(*f, ", ") }
+
+func (f *stringsFlag) Set(v string) error {
+	*f = append(*f, v)
+	return nil
+}
+
+// run executes test cases as configured by command line arguments and writes
+// results to w. It returns false if any test case failed.
+func run(args []string, w io.Writer) (bool, error) {
+	fs := flag.NewFlagSet("httpapitest", flag.ContinueOnError)
+	baseURL := fs.String("base-url", "", "base URL prepended to request paths")
+	format := fs.String("format", "tap", "output format: tap, junit or json")
+	output := fs.String("o", "", "output file, instead of the standard output")
+	timeout := fs.Duration("timeout", 30*time.Second, "timeout for a single request")
+	var headers, vars stringsFlag
+	fs.Var(&headers, "H", "request header `name: value` sent with every request, can be repeated")
+	fs.Var(&vars, "var", "variable `name=value` used in test case files, can be repeated")
+	if err := fs.Parse(args); err != nil {
+		return false, err
+	}
+	if fs.NArg() == 0 {
+		return false, errors.New("no test case files")
+	}
+	switch *format {
+	case "tap", "junit", "json":
+	default:
+		return false, fmt.Errorf("unknown format %q", *format)
+	}
+
+	store := new(httpapitest.Vars)
+	opts := []httpapitest.Option{httpapitest.WithVars(store)}
+	for _, v := range vars {
+		name, value, ok := strings.Cut(v, "=")
+		if !ok {
+			return false, fmt.Errorf("invalid variable %q", v)
+		}
+		store.Set(name, value)
+	}
+	for _, h := range headers {
+		name, value, ok := strings.Cut(h, ":")
+		if !ok {
+			return false, fmt.Errorf("invalid header %q", h)
+		}
+		opts = append(opts, httpapitest.WithRequestHeader(strings.TrimSpace(name), strings.TrimSpace(value)))
+	}
+
+	var cases []httpapitest.Case
+	for _, path := range fs.Args() {
+		c, err := httpapitest.LoadCases(*baseURL, path)
+		if err != nil {
+			return false, err
+		}
+		cases = append(cases, c...)
+	}
+
+	client := &http.Client{Timeout: *timeout}
+	report := new(httpapitest.Report)
+	opts = append(opts, httpapitest.WithReport(report))
+	ok := true
+	for _, c := range cases {
+		failures, err := httpapitest.Check(client, c.Method, c.URL, append(opts[:len(opts):len(opts)], append(c.Options, httpapitest.WithName(c.Name))...)...)
+		if len(failures) > 0 || err != nil {
+			ok = false
+		}
+	}
+
+	var buf bytes.Buffer
+	var err error
+	switch *format {
+	case "tap":
+		err = writeTAP(&buf, report.Entries())
+	case "junit":
+		err = report.WriteJUnit(&buf)
+	case "json":
+		err = report.WriteJSON(&buf)
+	}
+	if err != nil {
+		return false, err
+	}
+	if *output != "" {
+		if err := os.WriteFile(*output, buf.Bytes(), 0o666); err != nil {
+			return false, err
+		}
+	} else if _, err := w.Write(buf.Bytes()); err != nil {
+		return false, err
+	}
+	return ok, nil
+}
+
+// writeTAP writes results in the Test Anything Protocol version 13 format.
+func writeTAP(w io.Writer, entries []httpapitest.ReportEntry) error {
+	var b strings.Builder
+	b.WriteString("TAP version 13\n")
+	fmt.Fprintf(&b, "1..%v\n", len(entries))
+	for i, e := range entries {
+		if e.Passed() {
+			fmt.Fprintf(&b, "ok %v - %s\n", i+1, e.Name)
+			continue
+		}
+		fmt.Fprintf(&b, "not ok %v - %s\n", i+1, e.Name)
+		b.WriteString("  ---\n")
+		fmt.Fprintf(&b, "  request: %q\n", e.Method+" "+e.URL)
+		if e.Status != 0 {
+			fmt.Fprintf(&b, "  status: %v\n", e.Status)
+		}
+		fmt.Fprintf(&b, "  duration_ms: %v\n", e.Duration.Milliseconds())
+		if len(e.Failures) > 0 {
+			b.WriteString("  failures:\n")
+			for _, f := range e.Failures {
+				fmt.Fprintf(&b, "    - %q\n", f.Message)
+			}
+		}
+		if e.Error != "" {
+			fmt.Fprintf(&b, "  error: %q\n", e.Error)
+		}
+		b.WriteString("  ...\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
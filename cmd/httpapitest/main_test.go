@@ -0,0 +1,160 @@
+// Copyright (c) 2023, Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/users/1":
+			fmt.Fprint(w, `{"id":1}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer s.Close()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "users.http"), []byte(`
+### get user
+# @expect status 200
+# @expect json $.id 1
+GET /users/{{id}}
+
+### missing user
+# @expect status 200
+GET /users/2
+`), 0o666); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("tap", func(t *testing.T) {
+		var buf bytes.Buffer
+		ok, err := run([]string{"-base-url", s.URL, "-H", "Authorization: Bearer secret", "-var", "id=1", dir}, &buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok {
+			t.Error("got passed, want failed")
+		}
+		want := regexp.MustCompile(`^TAP version 13
+1\.\.2
+ok 1 - users/get user
+not ok 2 - users/missing user
+  ---
+  request: "GET ` + regexp.QuoteMeta(s.URL) + `/users/2"
+  status: 404
+  duration_ms: \d+
+  failures:
+    - "got response status 404 Not Found, want 200 OK"
+  \.\.\.
+$`)
+		if !want.MatchString(buf.String()) {
+			t.Errorf("got output %q", buf.String())
+		}
+	})
+
+	t.Run("junit", func(t *testing.T) {
+		output := filepath.Join(t.TempDir(), "report.xml")
+		ok, err := run([]string{"-base-url", s.URL, "-format", "junit", "-o", output, "-var", "id=1", filepath.Join(dir, "users.http")}, &bytes.Buffer{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok {
+			t.Error("got passed, want failed")
+		}
+		b, err := os.ReadFile(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range []string{
+			`<testsuite name="httpapitest" tests="2" failures="2" errors="0"`,
+			`<testcase name="users/get user" classname="GET ` + s.URL + `/users/1"`,
+			`<failure message="got response status 401 Unauthorized, want 200 OK" type="status">`,
+		} {
+			if !strings.Contains(string(b), want) {
+				t.Errorf("output does not contain %q:\n%s", want, b)
+			}
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		var buf bytes.Buffer
+		ok, err := run([]string{"-base-url", s.URL, "-format", "json", "-H", "Authorization: Bearer secret", dir}, &buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok {
+			t.Error("got passed, want failed")
+		}
+		for _, want := range []string{
+			`"tests": 2,`,
+			`"passed": 0,`,
+			`"failed": 2,`,
+			`"error": "request url: undefined variable \"id\""`,
+		} {
+			if !strings.Contains(buf.String(), want) {
+				t.Errorf("output does not contain %q:\n%s", want, buf.String())
+			}
+		}
+	})
+
+	t.Run("invalid case", func(t *testing.T) {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "users.json"), []byte(`[
+			{"name": "get user", "request": {"path": "/users/1"}},
+			{"name": "invalid", "request": {"path": "/users/2", "body": {}, "bodyText": "text"}}
+		]`), 0o666); err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		ok, err := run([]string{"-base-url", s.URL, "-H", "Authorization: Bearer secret", dir}, &buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok {
+			t.Error("got passed, want failed")
+		}
+		want := regexp.MustCompile(`^TAP version 13
+1\.\.2
+ok 1 - users/get user
+not ok 2 - users/invalid
+  ---
+  request: "GET ` + regexp.QuoteMeta(s.URL) + `/users/2"
+  duration_ms: \d+
+  error: "request body and bodyText are mutually exclusive"
+  \.\.\.
+$`)
+		if !want.MatchString(buf.String()) {
+			t.Errorf("got output %q", buf.String())
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		if _, err := run([]string{"-format", "xml", dir}, &bytes.Buffer{}); err == nil || err.Error() != `unknown format "xml"` {
+			t.Errorf("got error %v", err)
+		}
+		if _, err := run(nil, &bytes.Buffer{}); err == nil || err.Error() != "no test case files" {
+			t.Errorf("got error %v", err)
+		}
+	})
+}
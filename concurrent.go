@@ -0,0 +1,275 @@
+// Copyright (c) 2023, Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httpapitest
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Response is the response of a request made by the Concurrently or
+// ConcurrentCases function.
+type Response struct {
+	// Name is the case name or the request index for the Concurrently
+	// function.
+	Name   string
+	Status int
+	Header http.Header
+	Body   []byte
+	// Failures are failed validations of the request options.
+	Failures []AssertionError
+	// Err is the error that stopped the validation of the request, if any.
+	Err error
+}
+
+// ResponsesExpectation validates an invariant across all responses of
+// concurrent requests. It returns an error that describes the failed
+// validation.
+type ResponsesExpectation func(responses []Response) error
+
+// ExpectStatusCount validates that exactly n responses have the status code.
+func ExpectStatusCount(code, n int) ResponsesExpectation {
+	return func(responses []Response) error {
+		var got int
+		for _, r := range responses {
+			if r.Status == code {
+				got++
+			}
+		}
+		if got != n {
+			return fmt.Errorf("got %v responses with status %v %s, want %v", got, code, http.StatusText(code), n)
+		}
+		return nil
+	}
+}
+
+// ExpectStatuses validates that every response has one of the status codes.
+func ExpectStatuses(codes ...int) ResponsesExpectation {
+	return func(responses []Response) error {
+		for _, r := range responses {
+			var found bool
+			for _, code := range codes {
+				if r.Status == code {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return fmt.Errorf("got response %s status %v %s, want one of %v", r.Name, r.Status, http.StatusText(r.Status), codes)
+			}
+		}
+		return nil
+	}
+}
+
+// ExpectSameJSONPath validates that JSON bodies of all responses have the same
+// value selected by the JSONPath expression, such as the id of a resource
+// created by idempotent requests. Expressions are the same as in the
+// ExpectJSONPath option.
+func ExpectSameJSONPath(expression string) ResponsesExpectation {
+	p, err := parseJSONPath(expression)
+	return func(responses []Response) error {
+		if err != nil {
+			return err
+		}
+		var want string
+		var wantName string
+		for _, r := range responses {
+			v, err := decodeJSON(r.Body)
+			if err != nil {
+				return fmt.Errorf("got response %s invalid json %q: %v", r.Name, string(r.Body), err)
+			}
+			got, ok := p.value(v)
+			if !ok {
+				return fmt.Errorf("got response %s no json value at %q", r.Name, expression)
+			}
+			s := jsonString(got)
+			if wantName == "" {
+				want, wantName = s, r.Name
+				continue
+			}
+			if s != want {
+				return fmt.Errorf("got response %s json value %s at %q, want %s as in response %s", r.Name, s, expression, want, wantName)
+			}
+		}
+		return nil
+	}
+}
+
+// ConcurrentConfig configures the Concurrently function.
+type ConcurrentConfig struct {
+	// Requests is the number of identical requests to make. It must be
+	// greater than zero.
+	Requests int
+	// Expectations are validated against all responses after all requests
+	// complete.
+	Expectations []ResponsesExpectation
+}
+
+// Concurrently is a testing helper function that makes the same request as the
+// Request function, with the same options, multiple times at the same moment,
+// to expose race conditions in the handling of concurrent requests. Failed
+// validations of options are reported for every request separately and then
+// all responses are validated against the configured expectations. Responses
+// are returned in the order of requests and their names are request indexes.
+// Options that log on failure, WithDumpOnFailure and WithCurlOnFailure, have
+// no effect.
+//
+// Example:
+//
+//	httpapitest.Concurrently(t, client, http.MethodPost, url+"/users", httpapitest.ConcurrentConfig{
+//		Requests: 10,
+//		Expectations: []httpapitest.ResponsesExpectation{
+//			httpapitest.ExpectStatusCount(http.StatusCreated, 1),
+//			httpapitest.ExpectStatusCount(http.StatusConflict, 9),
+//		},
+//	}, httpapitest.WithJSONRequestBody(user))
+func Concurrently(t testing.TB, client *http.Client, method, url string, config ConcurrentConfig, opts ...Option) []Response {
+	t.Helper()
+
+	if config.Requests <= 0 {
+		t.Fatal(errors.New("concurrently: number of requests must be greater than zero"))
+	}
+	o, err := newOptions(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next, err := o.replayable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	requests := make([]concurrentRequest, 0, config.Requests)
+	for i := 0; i < config.Requests; i++ {
+		requests = append(requests, concurrentRequest{
+			name:   fmt.Sprint(i),
+			method: method,
+			url:    url,
+			o:      next(),
+		})
+	}
+	return concurrently(t, client, requests, config.Expectations)
+}
+
+// ConcurrentCases is a testing helper function that makes requests of all
+// cases at the same moment, in the same way as the Concurrently function. The
+// Parallel field of cases is ignored.
+func ConcurrentCases(t testing.TB, client *http.Client, cases []Case, expectations ...ResponsesExpectation) []Response {
+	t.Helper()
+
+	requests := make([]concurrentRequest, 0, len(cases))
+	for _, c := range cases {
+		o, err := newOptions(c.Options)
+		if err != nil {
+			t.Fatal(fmt.Errorf("%s: %w", c.name(), err))
+		}
+		requests = append(requests, concurrentRequest{
+			name:   c.name(),
+			method: c.method(),
+			url:    c.URL,
+			o:      o,
+		})
+	}
+	return concurrently(t, client, requests, expectations)
+}
+
+type concurrentRequest struct {
+	name   string
+	method string
+	url    string
+	o      *options
+}
+
+func concurrently(t testing.TB, client *http.Client, requests []concurrentRequest, expectations []ResponsesExpectation) []Response {
+	t.Helper()
+
+	var name string
+	for _, r := range requests {
+		if r.o.report != nil {
+			name = t.Name()
+			break
+		}
+	}
+
+	responses := make([]Response, len(requests))
+	start := make(chan struct{})
+	var ready, done sync.WaitGroup
+	for i, r := range requests {
+		i, r := i, r
+		r.o.keepBody = true
+		ready.Add(1)
+		done.Add(1)
+		go func() {
+			defer done.Done()
+
+			ready.Done()
+			<-start
+			requestStart := time.Now()
+			res := r.o.do(client, r.method, r.url)
+			r.o.reportResult(name, r.method, r.url, requestStart, res)
+
+			resp := Response{
+				Name:     r.name,
+				Body:     res.exchange.responseBody,
+				Failures: res.failures,
+				Err:      res.err,
+			}
+			if x := res.exchange.response; x != nil {
+				resp.Status = x.StatusCode
+				resp.Header = x.Header
+			}
+			responses[i] = resp
+		}()
+	}
+	ready.Wait()
+	close(start)
+	done.Wait()
+
+	var failed bool
+	for _, r := range responses {
+		for _, f := range r.Failures {
+			t.Errorf("request %s: %s", r.Name, f.Message)
+			failed = true
+		}
+		if r.Err != nil {
+			t.Errorf("request %s: %v", r.Name, r.Err)
+			failed = true
+		}
+	}
+	for _, e := range expectations {
+		if err := e(responses); err != nil {
+			t.Errorf("concurrently: %v", err)
+			failed = true
+		}
+	}
+	if failed {
+		t.Logf("%s", responsesSummary(responses))
+	}
+	return responses
+}
+
+// responsesSummary returns the number of responses by their status codes.
+func responsesSummary(responses []Response) string {
+	counts := make(map[int]int)
+	for _, r := range responses {
+		counts[r.Status]++
+	}
+	codes := make([]int, 0, len(counts))
+	for code := range counts {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	parts := make([]string, 0, len(codes))
+	for _, code := range codes {
+		parts = append(parts, fmt.Sprintf("%v: %v", code, counts[code]))
+	}
+	return "responses by status: " + strings.Join(parts, ", ")
+}
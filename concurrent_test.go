@@ -0,0 +1,143 @@
+// Copyright (c) 2023, Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httpapitest_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"resenje.org/httpapitest"
+)
+
+func TestConcurrently(t *testing.T) {
+
+	var mu sync.Mutex
+	ids := make(map[string]int)
+	c, endpoint := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		key := string(b)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		id, ok := ids[key]
+		if ok {
+			respondJSON(w, http.StatusConflict, map[string]int{"id": id})
+			return
+		}
+		id = len(ids) + 1
+		ids[key] = id
+		respondJSON(w, http.StatusCreated, map[string]int{"id": id})
+	}))
+
+	var got []httpapitest.Response
+	assert(t, "", "", func(m *mock) {
+		got = httpapitest.Concurrently(m, c, http.MethodPost, endpoint, httpapitest.ConcurrentConfig{
+			Requests: 10,
+			Expectations: []httpapitest.ResponsesExpectation{
+				httpapitest.ExpectStatusCount(http.StatusCreated, 1),
+				httpapitest.ExpectStatusCount(http.StatusConflict, 9),
+				httpapitest.ExpectStatuses(http.StatusCreated, http.StatusConflict),
+				httpapitest.ExpectSameJSONPath("$.id"),
+			},
+		},
+			httpapitest.WithRequestBody(strings.NewReader("first")),
+		)
+	})
+	if len(got) != 10 {
+		t.Fatalf("got %v responses, want 10", len(got))
+	}
+	for i, r := range got {
+		if r.Name != fmt.Sprint(i) {
+			t.Errorf("got response name %q, want %q", r.Name, fmt.Sprint(i))
+		}
+		if strings.TrimSpace(string(r.Body)) != `{"id":1}` {
+			t.Errorf("got response body %q", string(r.Body))
+		}
+	}
+
+	assert(t, "concurrently: got 0 responses with status 201 Created, want 1", "", func(m *mock) {
+		httpapitest.Concurrently(m, c, http.MethodPost, endpoint, httpapitest.ConcurrentConfig{
+			Requests: 3,
+			Expectations: []httpapitest.ResponsesExpectation{
+				httpapitest.ExpectStatusCount(http.StatusCreated, 1),
+			},
+		},
+			httpapitest.WithRequestBody(strings.NewReader("first")),
+		)
+	})
+
+	assert(t, "request 0: got response status 409 Conflict, want 201 Created", "", func(m *mock) {
+		httpapitest.Concurrently(m, c, http.MethodPost, endpoint, httpapitest.ConcurrentConfig{
+			Requests: 1,
+		},
+			httpapitest.WithRequestBody(strings.NewReader("first")),
+			httpapitest.ExpectStatus(http.StatusCreated),
+		)
+	})
+}
+
+func TestConcurrently_simultaneous(t *testing.T) {
+
+	const n = 5
+
+	var wg sync.WaitGroup
+	wg.Add(n)
+	arrived := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(arrived)
+	}()
+	c, endpoint := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		wg.Done()
+		select {
+		case <-arrived:
+		case <-time.After(5 * time.Second):
+			w.WriteHeader(http.StatusGatewayTimeout)
+		}
+	}))
+
+	assert(t, "", "", func(m *mock) {
+		httpapitest.Concurrently(m, c, http.MethodGet, endpoint, httpapitest.ConcurrentConfig{
+			Requests: n,
+		},
+			httpapitest.ExpectStatus(http.StatusOK),
+		)
+	})
+}
+
+func TestConcurrentCases(t *testing.T) {
+
+	c, endpoint := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		respondJSON(w, http.StatusOK, map[string]string{"id": r.URL.Query().Get("id")})
+	}))
+
+	cases := func(secondID string) []httpapitest.Case {
+		return []httpapitest.Case{
+			{Name: "first", URL: endpoint + "?id=a"},
+			{Name: "second", URL: endpoint + "?id=" + secondID, Options: []httpapitest.Option{
+				httpapitest.ExpectStatus(http.StatusOK),
+			}},
+		}
+	}
+
+	var got []httpapitest.Response
+	assert(t, "", "", func(m *mock) {
+		got = httpapitest.ConcurrentCases(m, c, cases("a"), httpapitest.ExpectSameJSONPath("$.id"))
+	})
+	if len(got) != 2 || got[0].Name != "first" || got[1].Name != "second" {
+		t.Errorf("got responses %v", got)
+	}
+
+	assert(t, `concurrently: got response second json value "b" at "$.id", want "a" as in response first`, "", func(m *mock) {
+		httpapitest.ConcurrentCases(m, c, cases("b"), httpapitest.ExpectSameJSONPath("$.id"))
+	})
+}
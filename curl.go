@@ -0,0 +1,121 @@
+// Copyright (c) 2023, Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httpapitest
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxCurlInlineBodySize is the maximal size of the request body that is
+// included in the curl command line. Larger bodies are saved to files.
+const maxCurlInlineBodySize = 1024
+
+type curlOptions struct {
+	redact map[string]struct{}
+}
+
+// WithCurlOnFailure logs a curl command that reproduces the request made by
+// the Request function, if any validation fails. Values of headers in
+// DefaultRedactedHeaders and of the additionally provided headers are replaced
+// in the command. Request bodies that are larger than 1024 bytes, that are not
+// a valid UTF-8 text or that start with the @ character are saved to a file in
+// the directory specified by the HTTPAPITEST_ARTIFACTS_DIR environment
+// variable, or in the default directory for temporary files, and passed to the
+// command with the --data-binary @filename argument.
+func WithCurlOnFailure(redactHeaders ...string) Option {
+	return optionFunc(func(o *options) error {
+		o.curl = &curlOptions{
+			redact: redactedHeaders(redactHeaders),
+		}
+		return nil
+	})
+}
+
+// command returns the curl command for the exchange request.
+func (c *curlOptions) command(x *exchange) (string, error) {
+	r := x.request
+	args := []string{"curl"}
+	// --data-binary makes curl send a POST request, so the method is set
+	// explicitly whenever there is a request body
+	hasBody := len(x.requestBody) > 0
+	switch {
+	case r.Method == http.MethodGet && !hasBody:
+	case r.Method == http.MethodHead && !hasBody:
+		args = append(args, "--head")
+	default:
+		args = append(args, "-X", r.Method)
+	}
+	args = append(args, shellQuote(r.URL.String()))
+
+	keys := make([]string, 0, len(r.Header))
+	for k := range r.Header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range r.Header[k] {
+			if _, ok := c.redact[http.CanonicalHeaderKey(k)]; ok {
+				v = "[REDACTED]"
+			}
+			args = append(args, "-H", shellQuote(k+": "+v))
+		}
+	}
+	if r.Host != "" && r.Host != r.URL.Host {
+		args = append(args, "-H", shellQuote("Host: "+r.Host))
+	}
+
+	if body := x.requestBody; len(body) > 0 {
+		// curl reads the file named after the @ prefix of the argument
+		if len(body) <= maxCurlInlineBodySize && utf8.Valid(body) && bytes.IndexByte(body, 0) < 0 && body[0] != '@' {
+			args = append(args, "--data-binary", shellQuote(string(body)))
+		} else {
+			filename, err := saveCurlBody(body)
+			if err != nil {
+				return "", err
+			}
+			args = append(args, "--data-binary", shellQuote("@"+filename))
+		}
+	}
+
+	return strings.Join(args, " "), nil
+}
+
+// saveCurlBody writes the request body to a file that is not removed after
+// the test is done.
+func saveCurlBody(body []byte) (string, error) {
+	dir := os.Getenv(ArtifactsDirEnv)
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o777); err != nil {
+			return "", fmt.Errorf("create request body directory: %w", err)
+		}
+	}
+	f, err := os.CreateTemp(dir, "httpapitest-request-body-*")
+	if err != nil {
+		return "", fmt.Errorf("create request body file: %w", err)
+	}
+	if _, err := f.Write(body); err != nil {
+		f.Close()
+		return "", fmt.Errorf("write request body file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("close request body file: %w", err)
+	}
+	return f.Name(), nil
+}
+
+// shellQuote quotes the string to be used as a single POSIX shell argument.
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./:@=,+%") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,124 @@
+// Copyright (c) 2023, Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httpapitest_test
+
+import (
+	"bytes"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"resenje.org/httpapitest"
+)
+
+func TestWithCurlOnFailure(t *testing.T) {
+
+	c, endpoint := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+
+	m := new(mock)
+	httpapitest.Request(m, c, http.MethodPost, endpoint+"/path?q=1&p=it's",
+		httpapitest.WithRequestBody(strings.NewReader(`{"name":"it's"}`)),
+		httpapitest.WithRequestHeader("Authorization", "Bearer secret"),
+		httpapitest.WithRequestHeader("Content-Type", "application/json"),
+		httpapitest.WithRequestHeader("X-Api-Key", "secret"),
+		httpapitest.ExpectStatus(http.StatusOK),
+		httpapitest.WithCurlOnFailure("x-api-key"),
+	)
+
+	wantLogs := []string{
+		`curl -X POST '` + endpoint + `/path?q=1&p=it'\''s'` +
+			` -H 'Authorization: [REDACTED]'` +
+			` -H 'Content-Type: application/json'` +
+			` -H 'X-Api-Key: [REDACTED]'` +
+			` --data-binary '{"name":"it'\''s"}'`,
+	}
+	if got := withoutTiming(t, m.gotLogs); !reflect.DeepEqual(got, wantLogs) {
+		t.Errorf("got logs %q, want %q", got, wantLogs)
+	}
+
+	m = new(mock)
+	httpapitest.Request(m, c, http.MethodGet, endpoint,
+		httpapitest.WithRequestBody(strings.NewReader(`q=1`)),
+		httpapitest.ExpectStatus(http.StatusOK),
+		httpapitest.WithCurlOnFailure(),
+	)
+
+	wantLogs = []string{
+		`curl -X GET ` + endpoint + ` --data-binary q=1`,
+	}
+	if got := withoutTiming(t, m.gotLogs); !reflect.DeepEqual(got, wantLogs) {
+		t.Errorf("got logs %q, want %q", got, wantLogs)
+	}
+
+	m = new(mock)
+	httpapitest.Request(m, c, http.MethodGet, endpoint,
+		httpapitest.ExpectStatus(http.StatusBadRequest),
+		httpapitest.WithCurlOnFailure(),
+	)
+	if m.gotLogs != nil {
+		t.Errorf("got logs %q, want none", m.gotLogs)
+	}
+}
+
+func TestWithCurlOnFailure_bodyFile(t *testing.T) {
+
+	c, endpoint := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+
+	for _, tc := range []struct {
+		name string
+		body []byte
+	}{
+		{
+			name: "large",
+			body: bytes.Repeat([]byte{0, 1, 2, 3}, 1000),
+		},
+		{
+			name: "at sign",
+			body: []byte("@alice"),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			t.Setenv(httpapitest.ArtifactsDirEnv, dir)
+
+			m := new(mock)
+			httpapitest.Request(m, c, http.MethodPut, endpoint,
+				httpapitest.WithRequestBody(bytes.NewReader(tc.body)),
+				httpapitest.ExpectStatus(http.StatusOK),
+				httpapitest.WithCurlOnFailure(),
+			)
+
+			files, err := filepath.Glob(filepath.Join(dir, "httpapitest-request-body-*"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(files) != 1 {
+				t.Fatalf("got files %v, want one", files)
+			}
+			gotBody, err := os.ReadFile(files[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(gotBody, tc.body) {
+				t.Error("got different body in the file")
+			}
+
+			wantLogs := []string{
+				"curl -X PUT " + endpoint + " --data-binary @" + files[0],
+			}
+			if got := withoutTiming(t, m.gotLogs); !reflect.DeepEqual(got, wantLogs) {
+				t.Errorf("got logs %q, want %q", got, wantLogs)
+			}
+		})
+	}
+}
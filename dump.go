@@ -0,0 +1,130 @@
+// Copyright (c) 2023, Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httpapitest
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DumpEnv is the name of the environment variable that enables logging of the
+// request and the response on failure for every request made by the Request
+// function, as with the WithDumpOnFailure option. If its value is a number
+// greater than one, it is used as the maximal body size, otherwise bodies are
+// truncated to 4096 bytes. Values "0" and "false" disable dumping.
+const DumpEnv = "HTTPAPITEST_DUMP"
+
+const defaultDumpMaxBodySize = 4096
+
+// DefaultRedactedHeaders are headers that have their values redacted in dumps
+// produced by the WithDumpOnFailure option and in curl commands produced by
+// the WithCurlOnFailure option.
+var DefaultRedactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+type dumpOptions struct {
+	maxBodySize int
+	redact      map[string]struct{}
+}
+
+// WithDumpOnFailure logs the request made by the Request function and its
+// response as HTTP wire format text, if any validation fails. Bodies longer
+// than maxBodySize bytes are truncated, and if it is zero or negative bodies
+// are logged entirely. Values of headers in DefaultRedactedHeaders and of the
+// additionally provided headers are replaced in the output.
+func WithDumpOnFailure(maxBodySize int, redactHeaders ...string) Option {
+	return optionFunc(func(o *options) error {
+		o.dump = newDumpOptions(maxBodySize, redactHeaders)
+		return nil
+	})
+}
+
+func newDumpOptions(maxBodySize int, redactHeaders []string) *dumpOptions {
+	return &dumpOptions{
+		maxBodySize: maxBodySize,
+		redact:      redactedHeaders(redactHeaders),
+	}
+}
+
+// redactedHeaders returns a set of canonical header keys from
+// DefaultRedactedHeaders and additional headers.
+func redactedHeaders(additional []string) map[string]struct{} {
+	redact := make(map[string]struct{})
+	for _, h := range DefaultRedactedHeaders {
+		redact[http.CanonicalHeaderKey(h)] = struct{}{}
+	}
+	for _, h := range additional {
+		redact[http.CanonicalHeaderKey(h)] = struct{}{}
+	}
+	return redact
+}
+
+// dumpOptionsFromEnv returns dump options if they are enabled by the DumpEnv
+// environment variable.
+func dumpOptionsFromEnv() *dumpOptions {
+	v := os.Getenv(DumpEnv)
+	switch v {
+	case "", "0", "false":
+		return nil
+	}
+	maxBodySize := defaultDumpMaxBodySize
+	if n, err := strconv.Atoi(v); err == nil && n > 1 {
+		maxBodySize = n
+	}
+	return newDumpOptions(maxBodySize, nil)
+}
+
+// dump returns the request and the response in HTTP wire format.
+func (d *dumpOptions) dump(x *exchange) string {
+	var b strings.Builder
+	if r := x.request; r != nil {
+		b.WriteString("request:\n")
+		fmt.Fprintf(&b, "%s %s %s\n", r.Method, r.URL.RequestURI(), r.Proto)
+		fmt.Fprintf(&b, "Host: %s\n", r.Host)
+		d.writeHeader(&b, r.Header)
+		d.writeBody(&b, x.requestBody)
+	}
+	if r := x.response; r != nil {
+		b.WriteString("response:\n")
+		fmt.Fprintf(&b, "%s %s\n", r.Proto, r.Status)
+		d.writeHeader(&b, r.Header)
+		d.writeBody(&b, x.responseBody)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func (d *dumpOptions) writeHeader(b *strings.Builder, h http.Header) {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range h[k] {
+			if _, ok := d.redact[http.CanonicalHeaderKey(k)]; ok {
+				v = "[REDACTED]"
+			}
+			fmt.Fprintf(b, "%s: %s\n", k, v)
+		}
+	}
+	b.WriteString("\n")
+}
+
+func (d *dumpOptions) writeBody(b *strings.Builder, body []byte) {
+	if len(body) == 0 {
+		return
+	}
+	if d.maxBodySize > 0 && len(body) > d.maxBodySize {
+		b.Write(body[:d.maxBodySize])
+		fmt.Fprintf(b, "\n[%v bytes truncated]\n\n", len(body)-d.maxBodySize)
+		return
+	}
+	b.Write(body)
+	b.WriteString("\n\n")
+}
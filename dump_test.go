@@ -0,0 +1,114 @@
+// Copyright (c) 2023, Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httpapitest_test
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"resenje.org/httpapitest"
+)
+
+func TestWithDumpOnFailure(t *testing.T) {
+
+	c, endpoint := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Date", "Mon, 02 Jan 2006 15:04:05 GMT")
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.Header().Set("X-Token", "secret")
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(strings.Repeat("a", 20)))
+	}))
+
+	m := new(mock)
+	httpapitest.Request(m, c, http.MethodPost, endpoint+"/path?q=1",
+		httpapitest.WithRequestBody(strings.NewReader("request body")),
+		httpapitest.WithRequestHeader("Authorization", "Bearer secret"),
+		httpapitest.WithRequestHeader("Content-Type", "text/plain"),
+		httpapitest.ExpectStatus(http.StatusOK),
+		httpapitest.WithDumpOnFailure(10, "x-token"),
+	)
+
+	wantErrors := []string{"got response status 400 Bad Request, want 200 OK"}
+	if !reflect.DeepEqual(m.gotErrors, wantErrors) {
+		t.Errorf("got errors %q, want %q", m.gotErrors, wantErrors)
+	}
+	wantLogs := []string{
+		"request:\n" +
+			"POST /path?q=1 HTTP/1.1\n" +
+			"Host: " + strings.TrimPrefix(endpoint, "http://") + "\n" +
+			"Authorization: [REDACTED]\n" +
+			"Content-Type: text/plain\n" +
+			"\n" +
+			"request bo\n" +
+			"[2 bytes truncated]\n" +
+			"\n" +
+			"response:\n" +
+			"HTTP/1.1 400 Bad Request\n" +
+			"Content-Length: 20\n" +
+			"Content-Type: text/plain\n" +
+			"Date: Mon, 02 Jan 2006 15:04:05 GMT\n" +
+			"Set-Cookie: [REDACTED]\n" +
+			"X-Token: [REDACTED]\n" +
+			"\n" +
+			"aaaaaaaaaa\n" +
+			"[10 bytes truncated]\n",
+	}
+	if len(m.gotLogs) != 2 || !strings.HasPrefix(m.gotLogs[1], "timing: dns ") {
+		t.Fatalf("got logs %q, want dump and timing", m.gotLogs)
+	}
+	if !reflect.DeepEqual(m.gotLogs[:1], wantLogs) {
+		t.Errorf("got logs %q, want %q", m.gotLogs[:1], wantLogs)
+	}
+
+	m = new(mock)
+	httpapitest.Request(m, c, http.MethodGet, endpoint,
+		httpapitest.ExpectStatus(http.StatusBadRequest),
+		httpapitest.WithDumpOnFailure(0),
+	)
+	if m.gotErrors != nil || m.gotLogs != nil {
+		t.Errorf("got errors %q and logs %q, want none", m.gotErrors, m.gotLogs)
+	}
+}
+
+func TestWithDumpOnFailure_env(t *testing.T) {
+
+	t.Setenv(httpapitest.DumpEnv, "1")
+
+	c, endpoint := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Date", "Mon, 02 Jan 2006 15:04:05 GMT")
+		w.WriteHeader(http.StatusNotFound)
+	}))
+
+	var m *mock
+	assert(t, "", "EOF", func(mm *mock) {
+		m = mm
+		httpapitest.RequestJSON[int](m, c, http.MethodGet, endpoint,
+			httpapitest.WithRequestHeader("Cookie", "session=secret"),
+			httpapitest.StrictJSONDecoding(),
+		)
+	})
+
+	wantLogs := []string{
+		"request:\n" +
+			"GET / HTTP/1.1\n" +
+			"Host: " + strings.TrimPrefix(endpoint, "http://") + "\n" +
+			"Cookie: [REDACTED]\n" +
+			"\n" +
+			"response:\n" +
+			"HTTP/1.1 404 Not Found\n" +
+			"Content-Length: 0\n" +
+			"Date: Mon, 02 Jan 2006 15:04:05 GMT\n",
+	}
+	if len(m.gotLogs) != 2 || !strings.HasPrefix(m.gotLogs[1], "timing: dns ") {
+		t.Fatalf("got logs %q, want dump and timing", m.gotLogs)
+	}
+	if !reflect.DeepEqual(m.gotLogs[:1], wantLogs) {
+		t.Errorf("got logs %q, want %q", m.gotLogs[:1], wantLogs)
+	}
+}
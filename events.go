@@ -0,0 +1,210 @@
+// Copyright (c) 2023, Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httpapitest
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Event is a server-sent event from a text/event-stream response.
+type Event struct {
+	// ID is the last event ID set by the stream.
+	ID string
+	// Event is the event type, "message" if it is not set by the stream.
+	Event string
+	// Data is the event data, with lines separated by a newline.
+	Data string
+	// Retry is the reconnection time set with the event, if any.
+	Retry time.Duration
+}
+
+// EventMatcher validates a single event. It returns an error that describes
+// the failed validation.
+type EventMatcher func(e Event) error
+
+// MatchEvent validates that the event has the same non-empty fields as the
+// provided one.
+func MatchEvent(want Event) EventMatcher {
+	return func(e Event) error {
+		if want.ID != "" && e.ID != want.ID {
+			return fmt.Errorf("got id %q, want %q", e.ID, want.ID)
+		}
+		if want.Event != "" && e.Event != want.Event {
+			return fmt.Errorf("got type %q, want %q", e.Event, want.Event)
+		}
+		if want.Data != "" && e.Data != want.Data {
+			return fmt.Errorf("got data %q, want %q", e.Data, want.Data)
+		}
+		if want.Retry != 0 && e.Retry != want.Retry {
+			return fmt.Errorf("got retry %v, want %v", e.Retry, want.Retry)
+		}
+		return nil
+	}
+}
+
+// MatchEventJSONPath validates that the value selected by the JSONPath
+// expression from the JSON event data is equal to the JSON-encoded value
+// provided here, as with the ExpectJSONPath option.
+func MatchEventJSONPath(expression string, value interface{}) EventMatcher {
+	match := MatchJSONPath(expression, value)
+	return func(e Event) error {
+		v, err := decodeJSON([]byte(e.Data))
+		if err != nil {
+			return fmt.Errorf("got invalid json data %q: %v", e.Data, err)
+		}
+		return match(v)
+	}
+}
+
+type eventsOptions struct {
+	timeout  time.Duration
+	matchers []EventMatcher
+}
+
+// ExpectEvents validates that the response of the request made by the Request
+// function is a text/event-stream and that its events are matched by the
+// provided matchers in sequence, one matcher for every event. The response
+// body is read as a stream until all matchers are satisfied or the timeout
+// elapses, when the response body is closed. Options that require the whole
+// response body, such as ExpectJSONSchema, ExpectJSONPath or CaptureJSONPath,
+// can not be used together with this option, and the WithOpenAPI option does
+// not validate the response body against its schema.
+func ExpectEvents(timeout time.Duration, matchers ...EventMatcher) Option {
+	return optionFunc(func(o *options) error {
+		if timeout <= 0 {
+			return errors.New("events: timeout must be positive")
+		}
+		o.events = &eventsOptions{
+			timeout:  timeout,
+			matchers: matchers,
+		}
+		return nil
+	})
+}
+
+// validate reads events from the response body and matches them. It returns
+// the data that was read from the body.
+func (e *eventsOptions) validate(resp *http.Response) ([]byte, []AssertionError, error) {
+	contentType := resp.Header.Get("Content-Type")
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType != "text/event-stream" {
+		return nil, []AssertionError{{
+			Kind:     AssertionEvent,
+			Path:     "Content-Type",
+			Expected: "text/event-stream",
+			Actual:   contentType,
+			Message:  fmt.Sprintf("got response content type %q, want %q", contentType, "text/event-stream"),
+		}}, nil
+	}
+
+	var timedOut atomic.Bool
+	timer := time.AfterFunc(e.timeout, func() {
+		timedOut.Store(true)
+		resp.Body.Close()
+	})
+	defer timer.Stop()
+
+	var data bytes.Buffer
+	r := newEventReader(io.TeeReader(resp.Body, &data))
+	for i, match := range e.matchers {
+		event, err := r.next()
+		if err != nil {
+			if timedOut.Load() {
+				return data.Bytes(), []AssertionError{eventsMissingError(i, len(e.matchers), fmt.Sprintf("in %v", e.timeout))}, nil
+			}
+			if err == io.EOF {
+				return data.Bytes(), []AssertionError{eventsMissingError(i, len(e.matchers), "before the end of the stream")}, nil
+			}
+			return data.Bytes(), nil, err
+		}
+		if err := match(event); err != nil {
+			return data.Bytes(), []AssertionError{{
+				Kind:    AssertionEvent,
+				Path:    fmt.Sprintf("event %v", i+1),
+				Actual:  event.Data,
+				Message: fmt.Sprintf("event %v: %v", i+1, err),
+			}}, nil
+		}
+	}
+	return data.Bytes(), nil, nil
+}
+
+func eventsMissingError(got, want int, when string) AssertionError {
+	return AssertionError{
+		Kind:     AssertionEvent,
+		Expected: strconv.Itoa(want),
+		Actual:   strconv.Itoa(got),
+		Message:  fmt.Sprintf("got %v events %s, want %v", got, when, want),
+	}
+}
+
+// eventReader parses server-sent events as specified by the HTML standard.
+type eventReader struct {
+	r           *bufio.Reader
+	lastEventID string
+}
+
+func newEventReader(r io.Reader) *eventReader {
+	return &eventReader{r: bufio.NewReader(r)}
+}
+
+// next returns the next dispatched event.
+func (r *eventReader) next() (Event, error) {
+	var event Event
+	var data strings.Builder
+	var hasData bool
+	for {
+		line, err := r.r.ReadString('\n')
+		if err != nil {
+			return Event{}, err
+		}
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+
+		if line == "" {
+			if !hasData {
+				event = Event{}
+				continue
+			}
+			event.ID = r.lastEventID
+			if event.Event == "" {
+				event.Event = "message"
+			}
+			event.Data = strings.TrimSuffix(data.String(), "\n")
+			return event, nil
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			event.Event = value
+		case "data":
+			data.WriteString(value)
+			data.WriteByte('\n')
+			hasData = true
+		case "id":
+			if !strings.Contains(value, "\x00") {
+				r.lastEventID = value
+			}
+		case "retry":
+			if ms, err := strconv.ParseUint(value, 10, 63); err == nil {
+				event.Retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+}
@@ -0,0 +1,234 @@
+// Copyright (c) 2023, Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httpapitest_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"resenje.org/httpapitest"
+)
+
+func TestExpectEvents(t *testing.T) {
+
+	c, endpoint := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/plain" {
+			fmt.Fprint(w, "data: text\n\n")
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+		fmt.Fprint(w, ": connected\n\n")
+		fmt.Fprint(w, "retry: 1500\r\nid: 1\r\ndata: first\r\n\r\n")
+		fmt.Fprint(w, "event: update\ndata: {\"user\":\n")
+		fmt.Fprint(w, "data: {\"id\": 7}}\n\n")
+		w.(http.Flusher).Flush()
+		if r.URL.Path == "/short" {
+			return
+		}
+		// stream is kept open until the client disconnects
+		<-r.Context().Done()
+	}))
+
+	var got []httpapitest.Event
+	record := func(e httpapitest.Event) error {
+		got = append(got, e)
+		return nil
+	}
+
+	assert(t, "", "", func(m *mock) {
+		httpapitest.Request(m, c, http.MethodGet, endpoint,
+			httpapitest.ExpectStatus(http.StatusOK),
+			httpapitest.ExpectEvents(5*time.Second,
+				httpapitest.MatchEvent(httpapitest.Event{ID: "1", Event: "message", Data: "first", Retry: 1500 * time.Millisecond}),
+				httpapitest.MatchEventJSONPath("$.user.id", 7),
+			),
+		)
+	})
+
+	assert(t, "", "", func(m *mock) {
+		httpapitest.Request(m, c, http.MethodGet, endpoint,
+			httpapitest.ExpectEvents(5*time.Second, record, record),
+		)
+	})
+	want := []httpapitest.Event{
+		{ID: "1", Event: "message", Data: "first", Retry: 1500 * time.Millisecond},
+		{ID: "1", Event: "update", Data: "{\"user\":\n{\"id\": 7}}"},
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got events %q, want %q", got, want)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		path     string
+		matchers []httpapitest.EventMatcher
+		want     string
+	}{
+		{
+			name: "type",
+			matchers: []httpapitest.EventMatcher{
+				httpapitest.MatchEvent(httpapitest.Event{Data: "first"}),
+				httpapitest.MatchEvent(httpapitest.Event{Event: "delete"}),
+			},
+			want: `event 2: got type "update", want "delete"`,
+		},
+		{
+			name: "json path",
+			matchers: []httpapitest.EventMatcher{
+				httpapitest.MatchEventJSONPath("$.user.id", 7),
+			},
+			want: `event 1: got invalid json data "first": invalid character 'i' in literal false (expecting 'a')`,
+		},
+		{
+			name: "custom",
+			matchers: []httpapitest.EventMatcher{
+				func(e httpapitest.Event) error { return errors.New("unexpected") },
+			},
+			want: "event 1: unexpected",
+		},
+		{
+			name: "timeout",
+			matchers: []httpapitest.EventMatcher{
+				record, record, record,
+			},
+			want: "got 2 events in 100ms, want 3",
+		},
+		{
+			name: "end of stream",
+			path: "/short",
+			matchers: []httpapitest.EventMatcher{
+				record, record, record,
+			},
+			want: "got 2 events before the end of the stream, want 3",
+		},
+		{
+			name:     "content type",
+			path:     "/plain",
+			matchers: []httpapitest.EventMatcher{record},
+			want:     `got response content type "text/plain; charset=utf-8", want "text/event-stream"`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert(t, tc.want, "", func(m *mock) {
+				httpapitest.Request(m, c, http.MethodGet, endpoint+tc.path,
+					httpapitest.ExpectEvents(100*time.Millisecond, tc.matchers...),
+				)
+			})
+		})
+	}
+}
+
+// wholeBodyOptions returns options that require the whole response body by
+// their names.
+func wholeBodyOptions() []struct {
+	name string
+	opt  httpapitest.Option
+} {
+	return []struct {
+		name string
+		opt  httpapitest.Option
+	}{
+		{"ExpectJSONSchema", httpapitest.ExpectJSONSchema([]byte(`true`))},
+		{"ExpectJSONPath", httpapitest.ExpectJSONPath("$.id", 1)},
+		{"ExpectJSONSubset", httpapitest.ExpectJSONSubset(map[string]interface{}{"id": 1})},
+		{"CaptureJSONPath", httpapitest.CaptureJSONPath("id", "$.id")},
+		{"ExpectedResponse", httpapitest.ExpectedResponse(strings.NewReader("data"))},
+		{"ExpectedJSONResponse", httpapitest.ExpectedJSONResponse(map[string]interface{}{"id": 1})},
+		{"UnmarshalJSONResponse", httpapitest.UnmarshalJSONResponse(new(interface{}))},
+		{"PutResponseBody", httpapitest.PutResponseBody(new([]byte))},
+		{"ExpectNoResponseBody", httpapitest.ExpectNoResponseBody()},
+	}
+}
+
+func TestExpectEvents_wholeBodyOptions(t *testing.T) {
+
+	for _, tc := range wholeBodyOptions() {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := httpapitest.Check(http.DefaultClient, http.MethodGet, "http://localhost",
+				httpapitest.ExpectEvents(time.Second),
+				httpapitest.WithVars(new(httpapitest.Vars)),
+				tc.opt,
+			)
+			want := "response body is streamed by the ExpectEvents option and can not be used by the " + tc.name + " option"
+			if err == nil || err.Error() != want {
+				t.Errorf("got error %v, want %v", err, want)
+			}
+		})
+	}
+
+	assert(t, "", "response body is streamed by the ExpectEvents option and can not be used by the ExpectJSONPath option", func(m *mock) {
+		httpapitest.Request(m, http.DefaultClient, http.MethodGet, "http://localhost",
+			httpapitest.ExpectEvents(time.Second),
+			httpapitest.ExpectJSONPath("$.id", 1),
+		)
+	})
+}
+
+func TestExpectEvents_openAPI(t *testing.T) {
+
+	doc, err := httpapitest.LoadOpenAPI([]byte(`{
+		"openapi": "3.1.0",
+		"paths": {
+			"/events": {"get": {"responses": {"200": {"description": "events", "content": {"text/event-stream": {"schema": {"type": "string"}}}}}}},
+			"/plain": {"get": {"responses": {"200": {"description": "events", "content": {"text/event-stream": {}}}}}}
+		}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, endpoint := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/events" {
+			w.Header().Set("Content-Type", "text/event-stream")
+		}
+		fmt.Fprint(w, "data: first\n\n")
+	}))
+
+	var recorder httpapitest.HARRecorder
+	failures, err := httpapitest.Check(c, http.MethodGet, endpoint+"/events",
+		httpapitest.WithOpenAPI(doc),
+		httpapitest.WithHARRecorder(&recorder),
+		httpapitest.ExpectEvents(time.Second, httpapitest.MatchEvent(httpapitest.Event{Data: "first"})),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failures) != 0 {
+		t.Errorf("got failures %v", failures)
+	}
+
+	var buf bytes.Buffer
+	if _, err := recorder.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"text": "data: first\n\n"`) {
+		t.Errorf("got no response body in har %s", buf.String())
+	}
+
+	failures, err = httpapitest.Check(c, http.MethodGet, endpoint+"/plain",
+		httpapitest.WithOpenAPI(doc),
+		httpapitest.ExpectEvents(time.Second),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range failures {
+		got = append(got, f.Message)
+	}
+	want := []string{
+		`openapi operation GET /plain: response content type "text/plain" is not declared`,
+		`got response content type "text/plain; charset=utf-8", want "text/event-stream"`,
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got failures %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright (c) 2023, Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httpapitest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+type eventuallyOptions struct {
+	timeout  time.Duration
+	interval time.Duration
+	backoff  float64
+}
+
+// Eventually repeats the request made by the Request function until all
+// validations pass or the timeout elapses. The first retry is made after the
+// interval and every subsequent interval is multiplied by the backoff factor,
+// if it is greater than one. If validations do not pass before the timeout,
+// only failures of the last attempt are reported, and the number of attempts
+// is logged. No attempt is started after the timeout elapses, and an attempt
+// that is still in progress when the timeout elapses is canceled, as are all
+// attempts when the context set by the WithContext option is done.
+func Eventually(timeout, interval time.Duration, backoff float64) Option {
+	return optionFunc(func(o *options) error {
+		if timeout <= 0 {
+			return errors.New("eventually: timeout must be positive")
+		}
+		if interval <= 0 {
+			return errors.New("eventually: interval must be positive")
+		}
+		o.eventually = &eventuallyOptions{
+			timeout:  timeout,
+			interval: interval,
+			backoff:  backoff,
+		}
+		return nil
+	})
+}
+
+// do makes request attempts until one of them passes or the timeout elapses,
+// returning the result of the last attempt. Every attempt is made with a
+// context that is done when the timeout elapses or when the context set by the
+// WithContext option is done.
+func (e *eventuallyOptions) do(client *http.Client, method, url string, o *options) *result {
+	next, err := o.replayable()
+	if err != nil {
+		return &result{err: err}
+	}
+
+	parent := o.ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	start := time.Now()
+	deadline := start.Add(e.timeout)
+	ctx, cancel := context.WithDeadline(parent, deadline)
+	defer cancel()
+
+	interval := e.interval
+	r := new(result)
+	for {
+		attempt := next()
+		attempt.ctx = ctx
+		r.attempts++
+		r.exchange = exchange{}
+		r.failures, r.err = attempt.check(client, method, url, &r.exchange)
+		r.duration = time.Since(start)
+		if !r.failed() {
+			return r
+		}
+		if err := parent.Err(); err != nil {
+			r.err = err
+			return r
+		}
+
+		// the next attempt would start when its context is already done
+		if time.Until(deadline) <= interval {
+			return r
+		}
+		timer := time.NewTimer(interval)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			if err := parent.Err(); err != nil {
+				r.err = err
+			}
+			r.duration = time.Since(start)
+			return r
+		}
+		if e.backoff > 1 {
+			interval = time.Duration(float64(interval) * e.backoff)
+		}
+	}
+}
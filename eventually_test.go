@@ -0,0 +1,136 @@
+// Copyright (c) 2023, Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httpapitest_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"resenje.org/httpapitest"
+)
+
+func TestEventually(t *testing.T) {
+
+	var calls int32
+	c, endpoint := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		if b, _ := io.ReadAll(r.Body); string(b) != "job" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if n < 3 {
+			w.WriteHeader(http.StatusAccepted)
+			fmt.Fprint(w, `{"state":"pending"}`)
+			return
+		}
+		fmt.Fprint(w, `{"state":"done"}`)
+	}))
+
+	var got struct {
+		State string `json:"state"`
+	}
+	assert(t, "", "", func(m *mock) {
+		httpapitest.Request(m, c, http.MethodPost, endpoint,
+			httpapitest.Eventually(5*time.Second, time.Millisecond, 2),
+			httpapitest.WithRequestBody(strings.NewReader("job")),
+			httpapitest.ExpectStatus(http.StatusOK),
+			httpapitest.ExpectJSONPath("$.state", "done"),
+			httpapitest.UnmarshalJSONResponse(&got),
+		)
+	})
+	if calls != 3 {
+		t.Errorf("got %v attempts, want 3", calls)
+	}
+	if got.State != "done" {
+		t.Errorf("got state %q, want %q", got.State, "done")
+	}
+}
+
+func TestEventually_timeout(t *testing.T) {
+
+	var calls int32
+	c, endpoint := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprint(w, `{"state":"pending"}`)
+	}))
+
+	t.Run("errors", func(t *testing.T) {
+		var m *mock
+		assert(t, `got json value at "$.state" "pending", want "done"`, "", func(mm *mock) {
+			m = mm
+			httpapitest.Request(m, c, http.MethodGet, endpoint,
+				httpapitest.Eventually(50*time.Millisecond, 10*time.Millisecond, 1),
+				httpapitest.ExpectStatus(http.StatusOK),
+				httpapitest.ExpectJSONPath("$.state", "done"),
+			)
+		})
+		if len(m.gotErrors) != 2 || m.gotErrors[0] != "got response status 202 Accepted, want 200 OK" {
+			t.Errorf("got errors %q", m.gotErrors)
+		}
+		if logs := withoutTiming(t, m.gotLogs); len(logs) != 1 || !strings.HasPrefix(logs[0], "eventually: validation failed after ") {
+			t.Errorf("got logs %q", m.gotLogs)
+		}
+		if n := atomic.LoadInt32(&calls); n < 2 {
+			t.Errorf("got %v attempts, want more", n)
+		}
+	})
+
+	t.Run("fatal", func(t *testing.T) {
+		var got []int
+		assert(t, "", "json: cannot unmarshal object into Go value of type []int", func(m *mock) {
+			httpapitest.Request(m, c, http.MethodGet, endpoint,
+				httpapitest.Eventually(20*time.Millisecond, 5*time.Millisecond, 2),
+				httpapitest.UnmarshalJSONResponse(&got),
+			)
+		})
+	})
+
+	t.Run("hanging", func(t *testing.T) {
+		c, endpoint := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+		}))
+		start := time.Now()
+		assert(t, "", `Get "`+endpoint+`": context deadline exceeded`, func(m *mock) {
+			httpapitest.Request(m, c, http.MethodGet, endpoint,
+				httpapitest.Eventually(50*time.Millisecond, 10*time.Millisecond, 1),
+			)
+		})
+		if d := time.Since(start); d > time.Second {
+			t.Errorf("got duration %v, want it to end at the timeout", d)
+		}
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		assert(t, "got response status 202 Accepted, want 200 OK", "context deadline exceeded", func(m *mock) {
+			httpapitest.Request(m, c, http.MethodGet, endpoint,
+				httpapitest.Eventually(5*time.Second, time.Second, 1),
+				httpapitest.WithContext(ctx),
+				httpapitest.ExpectStatus(http.StatusOK),
+			)
+		})
+		if d := time.Since(start); d > time.Second {
+			t.Errorf("got duration %v, want it to end at the context cancelation", d)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		assert(t, "", "eventually: timeout must be positive", func(m *mock) {
+			httpapitest.Request(m, c, http.MethodGet, endpoint,
+				httpapitest.Eventually(0, time.Millisecond, 1),
+			)
+		})
+	})
+}
@@ -0,0 +1,358 @@
+// Copyright (c) 2023, Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httpapitest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// fileCase is a declarative test case read from a JSON file.
+type fileCase struct {
+	Name    string      `json:"name"`
+	Request fileRequest `json:"request"`
+	Expect  fileExpect  `json:"expect"`
+	Capture fileCapture `json:"capture"`
+
+	file  string
+	index int
+	multi bool
+}
+
+type fileRequest struct {
+	Method   string            `json:"method"`
+	Path     string            `json:"path"`
+	Headers  map[string]string `json:"headers"`
+	Body     json.RawMessage   `json:"body"`
+	BodyText *string           `json:"bodyText"`
+}
+
+type fileExpect struct {
+	Status   int                        `json:"status"`
+	Headers  map[string]string          `json:"headers"`
+	JSON     json.RawMessage            `json:"json"`
+	JSONPath map[string]json.RawMessage `json:"jsonPath"`
+	Body     *string                    `json:"body"`
+	NoBody   bool                       `json:"noBody"`
+}
+
+// fileCapture maps variable names to JSONPath expressions, header names and
+// cookie names of the response values that are captured.
+type fileCapture struct {
+	JSONPath map[string]string `json:"jsonPath"`
+	Headers  map[string]string `json:"headers"`
+	Cookies  map[string]string `json:"cookies"`
+}
+
+// RunFiles runs declarative test cases from all JSON and .http files in the
+// directory and its subdirectories as subtests, using the Request function
+// with the provided client. The base URL is prepended to the request path of
+// every test case that is not an absolute URL and the provided options are
+// applied to every request before the ones defined in the test case.
+//
+// Every file contains a single test case object or an array of them. A test
+// case object has the following fields:
+//
+//	name             subtest name, defaults to the file path without extension
+//	request.method   HTTP request method, defaults to GET
+//	request.path     path appended to the base URL
+//	request.headers  object with request header names and values
+//	request.body     JSON request body, sent with application/json content type
+//	request.bodyText request body as a string
+//	expect.status    expected response status code
+//	expect.headers   object with expected response header names and values
+//	expect.json      JSON value that the response body must contain, as with ExpectJSONSubset
+//	expect.jsonPath  object with JSONPath expressions and expected values, as with ExpectJSONPath
+//	expect.body      expected response body as a string
+//	expect.noBody    if true, the response must not have a body
+//	capture.jsonPath object with variable names and JSONPath expressions, as with CaptureJSONPath
+//	capture.headers  object with variable names and response header names, as with CaptureHeader
+//	capture.cookies  object with variable names and response cookie names, as with CaptureCookie
+//
+// Test cases are run in order and values captured by one test case are
+// interpolated as {{name}} in the request path, headers and body of the later
+// ones. Variables are stored in a new Vars store, unless one is provided with
+// the WithVars option.
+//
+// Example:
+//
+//	{
+//		"name": "get user",
+//		"request": {"method": "GET", "path": "/users/1"},
+//		"expect": {"status": 200, "json": {"id": 1}, "jsonPath": {"$.roles[0]": "admin"}}
+//	}
+//
+// Files with .http or .rest extension are in the REST Client format, with
+// requests separated by lines starting with ###, request line followed by
+// headers, an empty line and the body, file variables defined as @name = value
+// and referenced as {{name}}, and expectations written as comments before the
+// request line:
+//
+//	@token = secret
+//
+//	### get user
+//	# @expect status 200
+//	# @expect header Content-Type application/json
+//	# @expect json $.roles[0] "admin"
+//	# @expect json {"id": 1}
+//	# @capture name json $.name
+//	GET /users/1
+//	Authorization: Bearer {{token}}
+//
+//	### delete user
+//	# @expect status 204
+//	# @expect nobody
+//	DELETE /users/1
+//
+// Other expectations are "# @expect body text" for the exact response body,
+// and captures "# @capture name header Location" and "# @capture name cookie
+// session" for response header and cookie values.
+func RunFiles(t *testing.T, client *http.Client, baseURL, dir string, opts ...Option) {
+	t.Helper()
+
+	cases, err := LoadCases(baseURL, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Cases(t, client, cases, append([]Option{WithVars(new(Vars))}, opts...)...)
+}
+
+// LoadCases reads declarative test cases from all JSON and .http files in the
+// directory and its subdirectories, or from a single file, in the same format
+// as the RunFiles function. Test cases are returned in order, with the base URL
+// prepended to every request path that is not an absolute URL, to be run with
+// the Cases or the Check function. Options of a test case that has invalid
+// expectations return an error when they are applied. To capture variables,
+// test cases must be run with the WithVars option.
+func LoadCases(baseURL, dir string) ([]Case, error) {
+	cases, err := loadFileCases(dir)
+	if err != nil {
+		return nil, err
+	}
+	tcs := make([]Case, 0, len(cases))
+	for _, c := range cases {
+		o, err := c.options()
+		if err != nil {
+			o = []Option{errorOption(err)}
+		}
+		tcs = append(tcs, Case{
+			Name:    c.name(),
+			Method:  c.method(),
+			URL:     c.url(baseURL),
+			Options: o,
+		})
+	}
+	return tcs, nil
+}
+
+// errorOption returns an option that fails with the provided error when it is
+// applied.
+func errorOption(err error) Option {
+	return optionFunc(func(*options) error {
+		return err
+	})
+}
+
+// loadFileCases reads test cases from all JSON and .http files in the
+// directory, or from a single file.
+func loadFileCases(dir string) ([]fileCase, error) {
+	var files []string
+	if err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		switch ext := filepath.Ext(path); {
+		case strings.EqualFold(ext, ".json"), strings.EqualFold(ext, ".http"), strings.EqualFold(ext, ".rest"):
+			files = append(files, path)
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("read test files: %w", err)
+	}
+	sort.Strings(files)
+
+	var cases []fileCase
+	for _, path := range files {
+		name, err := filepath.Rel(dir, path)
+		if err != nil {
+			name = path
+		}
+		if name == "." {
+			name = filepath.Base(path)
+		}
+		name = filepath.ToSlash(strings.TrimSuffix(name, filepath.Ext(name)))
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read test file: %w", err)
+		}
+		var c []fileCase
+		if strings.EqualFold(filepath.Ext(path), ".json") {
+			c, err = decodeFileCases(data)
+		} else {
+			c, err = parseHTTPFile(data)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		for i := range c {
+			c[i].file = name
+			c[i].index = i
+			c[i].multi = len(c) > 1
+		}
+		cases = append(cases, c...)
+	}
+	return cases, nil
+}
+
+// decodeFileCases decodes a single test case object or an array of them,
+// rejecting unknown fields.
+func decodeFileCases(data []byte) ([]fileCase, error) {
+	data = bytes.TrimSpace(data)
+	var cases []fileCase
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if bytes.HasPrefix(data, []byte("[")) {
+		if err := dec.Decode(&cases); err != nil {
+			return nil, err
+		}
+	} else {
+		var c fileCase
+		if err := dec.Decode(&c); err != nil {
+			return nil, err
+		}
+		cases = append(cases, c)
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return nil, errors.New("unexpected data after json value")
+	}
+	return cases, nil
+}
+
+func (c fileCase) name() string {
+	switch {
+	case c.Name != "" && c.multi:
+		return c.file + "/" + c.Name
+	case c.Name != "":
+		return c.Name
+	case c.multi:
+		return fmt.Sprintf("%s/%v", c.file, c.index)
+	}
+	return c.file
+}
+
+// url returns the request URL, prepending the base URL to the request path if
+// it is not an absolute URL.
+func (c fileCase) url(baseURL string) string {
+	if strings.HasPrefix(c.Request.Path, "http://") || strings.HasPrefix(c.Request.Path, "https://") {
+		return c.Request.Path
+	}
+	return baseURL + c.Request.Path
+}
+
+func (c fileCase) method() string {
+	if c.Request.Method == "" {
+		return http.MethodGet
+	}
+	return strings.ToUpper(c.Request.Method)
+}
+
+// options returns Request function options for the test case.
+func (c fileCase) options() ([]Option, error) {
+	var opts []Option
+
+	headers := make([]string, 0, len(c.Request.Headers))
+	for k := range c.Request.Headers {
+		headers = append(headers, k)
+	}
+	sort.Strings(headers)
+	for _, k := range headers {
+		opts = append(opts, WithRequestHeader(k, c.Request.Headers[k]))
+	}
+	switch {
+	case c.Request.Body != nil && c.Request.BodyText != nil:
+		return nil, errors.New("request body and bodyText are mutually exclusive")
+	case c.Request.Body != nil:
+		opts = append(opts, WithRequestBody(bytes.NewReader(c.Request.Body)))
+		if _, ok := headerValue(c.Request.Headers, "Content-Type"); !ok {
+			opts = append(opts, WithRequestHeader("Content-Type", "application/json"))
+		}
+	case c.Request.BodyText != nil:
+		opts = append(opts, WithRequestBody(strings.NewReader(*c.Request.BodyText)))
+	}
+
+	if c.Expect.Status != 0 {
+		opts = append(opts, ExpectStatus(c.Expect.Status))
+	}
+	headers = headers[:0]
+	for k := range c.Expect.Headers {
+		headers = append(headers, k)
+	}
+	sort.Strings(headers)
+	for _, k := range headers {
+		opts = append(opts, ExpectResponseHeader(k, c.Expect.Headers[k]))
+	}
+	if c.Expect.JSON != nil {
+		opts = append(opts, ExpectJSONSubset(c.Expect.JSON))
+	}
+	paths := make([]string, 0, len(c.Expect.JSONPath))
+	for p := range c.Expect.JSONPath {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		opts = append(opts, ExpectJSONPath(p, c.Expect.JSONPath[p]))
+	}
+	for _, k := range sortedKeys(c.Capture.JSONPath) {
+		opts = append(opts, CaptureJSONPath(k, c.Capture.JSONPath[k]))
+	}
+	for _, k := range sortedKeys(c.Capture.Headers) {
+		opts = append(opts, CaptureHeader(k, c.Capture.Headers[k]))
+	}
+	for _, k := range sortedKeys(c.Capture.Cookies) {
+		opts = append(opts, CaptureCookie(k, c.Capture.Cookies[k]))
+	}
+	switch {
+	case c.Expect.Body != nil && c.Expect.NoBody:
+		return nil, errors.New("expect body and noBody are mutually exclusive")
+	case c.Expect.Body != nil:
+		opts = append(opts, ExpectedResponse(strings.NewReader(*c.Expect.Body)))
+	case c.Expect.NoBody:
+		opts = append(opts, ExpectNoResponseBody())
+	}
+	return opts, nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func headerValue(headers map[string]string, key string) (string, bool) {
+	for k, v := range headers {
+		if strings.EqualFold(k, key) {
+			return v, true
+		}
+	}
+	return "", false
+}
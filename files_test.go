@@ -0,0 +1,91 @@
+// Copyright (c) 2023, Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httpapitest_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"resenje.org/httpapitest"
+)
+
+func TestRunFiles(t *testing.T) {
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"get.json": `{
+			"request": {"path": "/users/1", "headers": {"Accept": "application/json"}},
+			"expect": {
+				"status": 200,
+				"headers": {"Content-Type": "application/json"},
+				"json": {"id": 1},
+				"jsonPath": {"$.roles[0]": "admin", "$.roles[*]": ["admin", "user"]}
+			}
+		}`,
+		"users/crud.json": `[
+			{
+				"name": "create",
+				"request": {"method": "post", "path": "/users", "body": {"name": "test"}},
+				"expect": {"status": 201, "json": {"name": "test"}}
+			},
+			{
+				"request": {"method": "DELETE", "path": "/users/1", "bodyText": "plain"},
+				"expect": {"status": 204, "noBody": true}
+			}
+		]`,
+		"ignored.txt": `not a test`,
+	})
+
+	var mu sync.Mutex
+	var got []string
+	c, endpoint := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		got = append(got, r.Method+" "+r.URL.Path+" "+r.Header.Get("Content-Type")+" "+string(b))
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method {
+		case http.MethodPost:
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write(b)
+		case http.MethodDelete:
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"id": 1, "name": "test", "roles": []string{"admin", "user"}})
+		}
+	}))
+
+	httpapitest.RunFiles(t, c, endpoint, dir)
+
+	want := []string{
+		"GET /users/1  ",
+		`POST /users application/json {"name": "test"}`,
+		"DELETE /users/1  plain",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got requests %q, want %q", got, want)
+	}
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		filename := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(filename), 0o777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filename, []byte(content), 0o666); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,362 @@
+// Copyright (c) 2023, Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httpapitest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// ArtifactsDirEnv is the name of the environment variable that specifies the
+// directory where files, such as HAR recordings, are saved to be preserved
+// after the tests are done.
+const ArtifactsDirEnv = "HTTPAPITEST_ARTIFACTS_DIR"
+
+// HARRecorder records request and response exchanges made by the Request
+// function in HTTP Archive (HAR) 1.2 format. Values of headers in
+// DefaultRedactedHeaders and of cookies are redacted. The zero value is ready
+// to use and it is safe for concurrent use.
+type HARRecorder struct {
+	mu      sync.Mutex
+	entries []harEntry
+	redact  []string
+}
+
+// NewHARRecorder returns a new HARRecorder that writes recorded exchanges to a
+// HAR file when the test and all its subtests complete. If the filename is
+// empty, it is derived from the test name. Relative filenames are placed in
+// the directory specified by the HTTPAPITEST_ARTIFACTS_DIR environment
+// variable or, if it is not set, in a new temporary directory only if the
+// test failed. The path of the file is logged if the test failed. Values of
+// the provided headers are redacted in addition to DefaultRedactedHeaders.
+func NewHARRecorder(t testing.TB, filename string, redactHeaders ...string) *HARRecorder {
+	t.Helper()
+
+	r := &HARRecorder{redact: redactHeaders}
+	saveArtifact(t, filename, ".har", "har file", r.WriteFile)
+	return r
+}
+
+// WithHARRecorder records the exchange made by the Request function with the
+// HARRecorder. Set this option as a Client default option to record every
+// request made by the client.
+func WithHARRecorder(r *HARRecorder) Option {
+	return optionFunc(func(o *options) error {
+		o.har = r
+		return nil
+	})
+}
+
+// WriteTo writes all recorded exchanges as a JSON-encoded HAR document.
+func (r *HARRecorder) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	entries := make([]harEntry, len(r.entries))
+	copy(entries, r.entries)
+	r.mu.Unlock()
+
+	h := har{Log: harLog{
+		Version: "1.2",
+		Creator: harCreator{Name: "httpapitest", Version: "1.0"},
+		Entries: entries,
+	}}
+	data, err := json.MarshalIndent(h, "", "\t")
+	if err != nil {
+		return 0, fmt.Errorf("json encode har: %w", err)
+	}
+	n, err := w.Write(append(data, '\n'))
+	return int64(n), err
+}
+
+// WriteFile writes all recorded exchanges as a HAR document to the file,
+// creating its directory if needed.
+func (r *HARRecorder) WriteFile(filename string) error {
+	if err := os.MkdirAll(filepath.Dir(filename), 0o777); err != nil {
+		return err
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if _, err := r.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (r *HARRecorder) record(start time.Time, wait, total time.Duration, req *http.Request, requestBody []byte, resp *http.Response, responseBody []byte) {
+	redact := redactedHeaders(r.redact)
+	e := harEntry{
+		StartedDateTime: start.Format(time.RFC3339Nano),
+		Time:            milliseconds(total),
+		Request: harRequest{
+			Method:      req.Method,
+			URL:         req.URL.String(),
+			HTTPVersion: req.Proto,
+			Cookies:     harRequestCookies(req.Cookies(), redact),
+			Headers:     harHeaders(req.Header, redact),
+			QueryString: harQueryString(req),
+			HeadersSize: -1,
+			BodySize:    len(requestBody),
+		},
+		Response: harResponse{
+			Status:      resp.StatusCode,
+			StatusText:  strings.TrimSpace(strings.TrimPrefix(resp.Status, fmt.Sprint(resp.StatusCode))),
+			HTTPVersion: resp.Proto,
+			Cookies:     harResponseCookies(resp.Cookies(), redact),
+			Headers:     harHeaders(resp.Header, redact),
+			Content:     harBody(resp.Header.Get("Content-Type"), responseBody),
+			RedirectURL: resp.Header.Get("Location"),
+			HeadersSize: -1,
+			BodySize:    len(responseBody),
+		},
+		Cache: struct{}{},
+		Timings: harTimings{
+			Send:    0,
+			Wait:    milliseconds(wait),
+			Receive: milliseconds(total - wait),
+		},
+	}
+	if len(requestBody) > 0 {
+		c := harBody(req.Header.Get("Content-Type"), requestBody)
+		e.Request.PostData = &harPostData{
+			MimeType: c.MimeType,
+			Params:   make([]harNameValue, 0),
+			Text:     c.Text,
+			Encoding: c.Encoding,
+		}
+	}
+
+	r.mu.Lock()
+	r.entries = append(r.entries, e)
+	r.mu.Unlock()
+}
+
+func harHeaders(h http.Header, redact map[string]struct{}) []harNameValue {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	l := make([]harNameValue, 0, len(h))
+	for _, name := range names {
+		for _, value := range h[name] {
+			if _, ok := redact[http.CanonicalHeaderKey(name)]; ok {
+				value = "[REDACTED]"
+			}
+			l = append(l, harNameValue{Name: name, Value: value})
+		}
+	}
+	return l
+}
+
+func harQueryString(r *http.Request) []harNameValue {
+	q := r.URL.Query()
+	names := make([]string, 0, len(q))
+	for name := range q {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	l := make([]harNameValue, 0, len(q))
+	for _, name := range names {
+		for _, value := range q[name] {
+			l = append(l, harNameValue{Name: name, Value: value})
+		}
+	}
+	return l
+}
+
+func harRequestCookies(cookies []*http.Cookie, redact map[string]struct{}) []harCookie {
+	_, redacted := redact["Cookie"]
+	l := make([]harCookie, 0, len(cookies))
+	for _, c := range cookies {
+		l = append(l, harCookie{Name: c.Name, Value: harCookieValue(c.Value, redacted)})
+	}
+	return l
+}
+
+func harResponseCookies(cookies []*http.Cookie, redact map[string]struct{}) []harCookie {
+	_, redacted := redact["Set-Cookie"]
+	l := make([]harCookie, 0, len(cookies))
+	for _, c := range cookies {
+		hc := harCookie{
+			Name:     c.Name,
+			Value:    harCookieValue(c.Value, redacted),
+			Path:     c.Path,
+			Domain:   c.Domain,
+			HTTPOnly: c.HttpOnly,
+			Secure:   c.Secure,
+		}
+		if !c.Expires.IsZero() {
+			hc.Expires = c.Expires.Format(time.RFC3339)
+		}
+		l = append(l, hc)
+	}
+	return l
+}
+
+func harCookieValue(v string, redacted bool) string {
+	if redacted {
+		return "[REDACTED]"
+	}
+	return v
+}
+
+// harBody returns the HAR content of the body, encoding it with base64 if it
+// is not a valid UTF-8 text.
+func harBody(contentType string, body []byte) harContent {
+	c := harContent{
+		Size:     len(body),
+		MimeType: contentType,
+	}
+	if c.MimeType == "" && len(body) > 0 {
+		c.MimeType = http.DetectContentType(body)
+	}
+	if utf8.Valid(body) {
+		c.Text = string(body)
+	} else {
+		c.Text = base64.StdEncoding.EncodeToString(body)
+		c.Encoding = "base64"
+	}
+	return c
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// saveArtifact writes the file that is preserved after the test is done with
+// the write function when the test and all its subtests complete. If the
+// filename is empty, it is derived from the test name with the provided
+// extension. Relative filenames are joined with the directory from the
+// HTTPAPITEST_ARTIFACTS_DIR environment variable or, if it is not set, with a
+// new temporary directory, but only if the test failed, not to leave files
+// of passed tests behind. The path of the file is logged if the test failed.
+func saveArtifact(t testing.TB, filename, ext, kind string, write func(filename string) error) {
+	if filename == "" {
+		filename = strings.NewReplacer("/", "_", "\\", "_", ":", "_", " ", "_").Replace(t.Name()) + ext
+	}
+	t.Cleanup(func() {
+		if !filepath.IsAbs(filename) {
+			dir := os.Getenv(ArtifactsDirEnv)
+			if dir == "" {
+				if !t.Failed() {
+					return
+				}
+				// test temporary directory is removed before the file is
+				// written
+				d, err := os.MkdirTemp("", "httpapitest-")
+				if err != nil {
+					t.Errorf("write %s: %v", kind, err)
+					return
+				}
+				dir = d
+			}
+			filename = filepath.Join(dir, filename)
+		}
+		if err := write(filename); err != nil {
+			t.Errorf("write %s: %v", kind, err)
+			return
+		}
+		if t.Failed() {
+			t.Logf("%s: %s", kind, filename)
+		}
+	})
+}
+
+type har struct {
+	Log harLog `json:"log"`
+}
+
+type harLog struct {
+	Version string     `json:"version"`
+	Creator harCreator `json:"creator"`
+	Entries []harEntry `json:"entries"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harCookie    `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harCookie    `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harCookie struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Path     string `json:"path,omitempty"`
+	Domain   string `json:"domain,omitempty"`
+	Expires  string `json:"expires,omitempty"`
+	HTTPOnly bool   `json:"httpOnly,omitempty"`
+	Secure   bool   `json:"secure,omitempty"`
+}
+
+type harPostData struct {
+	MimeType string         `json:"mimeType"`
+	Params   []harNameValue `json:"params"`
+	Text     string         `json:"text"`
+	Encoding string         `json:"encoding,omitempty"`
+}
+
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+type harTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
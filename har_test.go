@@ -0,0 +1,257 @@
+// Copyright (c) 2023, Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httpapitest_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"resenje.org/httpapitest"
+)
+
+type testHAR struct {
+	Log struct {
+		Version string `json:"version"`
+		Entries []struct {
+			Request struct {
+				Method   string `json:"method"`
+				URL      string `json:"url"`
+				Headers  []struct{ Name, Value string }
+				PostData *struct {
+					MimeType string `json:"mimeType"`
+					Text     string `json:"text"`
+				} `json:"postData"`
+			} `json:"request"`
+			Response struct {
+				Status  int `json:"status"`
+				Content struct {
+					Size     int    `json:"size"`
+					MimeType string `json:"mimeType"`
+					Text     string `json:"text"`
+					Encoding string `json:"encoding"`
+				} `json:"content"`
+			} `json:"response"`
+		} `json:"entries"`
+	} `json:"log"`
+}
+
+func TestWithHARRecorder(t *testing.T) {
+
+	c, endpoint := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte{0xff, 0xfe})
+			return
+		}
+		respondJSON(w, http.StatusOK, "text")
+	}))
+
+	var recorder httpapitest.HARRecorder
+	client := httpapitest.NewClient(c, httpapitest.WithHARRecorder(&recorder))
+
+	var gotBody []byte
+	assert(t, "", "", func(m *mock) {
+		client.Request(m, http.MethodGet, endpoint+"/?q=1",
+			httpapitest.WithRequestHeader("Accept", "application/json"),
+			httpapitest.PutResponseBody(&gotBody),
+		)
+	})
+	if !strings.Contains(string(gotBody), `"message":"text"`) {
+		t.Errorf("got response body %q", string(gotBody))
+	}
+
+	assert(t, "", "", func(m *mock) {
+		client.Request(m, http.MethodPost, endpoint,
+			httpapitest.WithJSONRequestBody(map[string]string{"name": "test"}),
+			httpapitest.WithRequestHeader("Content-Type", "application/json"),
+		)
+	})
+
+	var buf bytes.Buffer
+	if _, err := recorder.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var h testHAR
+	if err := json.Unmarshal(buf.Bytes(), &h); err != nil {
+		t.Fatal(err)
+	}
+	if h.Log.Version != "1.2" {
+		t.Errorf("got version %q, want %q", h.Log.Version, "1.2")
+	}
+	if len(h.Log.Entries) != 2 {
+		t.Fatalf("got %v entries, want 2", len(h.Log.Entries))
+	}
+
+	get := h.Log.Entries[0]
+	if get.Request.Method != http.MethodGet || get.Request.URL != endpoint+"/?q=1" {
+		t.Errorf("got request %s %s", get.Request.Method, get.Request.URL)
+	}
+	if len(get.Request.Headers) != 1 || get.Request.Headers[0].Name != "Accept" {
+		t.Errorf("got request headers %v", get.Request.Headers)
+	}
+	if get.Request.PostData != nil {
+		t.Errorf("got post data %v, want none", get.Request.PostData)
+	}
+	if get.Response.Status != http.StatusOK || get.Response.Content.Text != string(gotBody) || get.Response.Content.MimeType != "application/json; charset=utf-8" {
+		t.Errorf("got response %+v", get.Response)
+	}
+
+	post := h.Log.Entries[1]
+	if post.Request.PostData == nil || post.Request.PostData.Text != `{"name":"test"}` || post.Request.PostData.MimeType != "application/json" {
+		t.Errorf("got post data %+v", post.Request.PostData)
+	}
+	if post.Response.Status != http.StatusCreated || post.Response.Content.Encoding != "base64" || post.Response.Content.Text != "//4=" || post.Response.Content.Size != 2 {
+		t.Errorf("got response %+v", post.Response)
+	}
+}
+
+func TestNewHARRecorder(t *testing.T) {
+
+	dir := t.TempDir()
+	t.Setenv(httpapitest.ArtifactsDirEnv, dir)
+
+	c, endpoint := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	t.Run("sub", func(t *testing.T) {
+		recorder := httpapitest.NewHARRecorder(t, "")
+		httpapitest.Request(t, c, http.MethodGet, endpoint, httpapitest.WithHARRecorder(recorder))
+	})
+
+	data, err := os.ReadFile(filepath.Join(dir, "TestNewHARRecorder_sub.har"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var h testHAR
+	if err := json.Unmarshal(data, &h); err != nil {
+		t.Fatal(err)
+	}
+	if len(h.Log.Entries) != 1 {
+		t.Errorf("got %v entries, want 1", len(h.Log.Entries))
+	}
+}
+
+func TestWithHARRecorder_redactedHeaders(t *testing.T) {
+
+	c, endpoint := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "secret"})
+	}))
+
+	var recorder httpapitest.HARRecorder
+	httpapitest.Request(t, c, http.MethodGet, endpoint,
+		httpapitest.WithHARRecorder(&recorder),
+		httpapitest.WithRequestHeader("Authorization", "Bearer secret"),
+		httpapitest.WithRequestHeader("Cookie", "session=secret"),
+		httpapitest.WithRequestHeader("Accept", "text/plain"),
+	)
+
+	var buf bytes.Buffer
+	if _, err := recorder.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "secret") {
+		t.Errorf("got unredacted values in %s", buf.String())
+	}
+	if !strings.Contains(buf.String(), "text/plain") {
+		t.Errorf("got redacted Accept header in %s", buf.String())
+	}
+	if got := strings.Count(buf.String(), `"[REDACTED]"`); got != 5 {
+		t.Errorf("got %v redacted values, want 5", got)
+	}
+}
+
+func TestNewHARRecorder_temporaryDirectory(t *testing.T) {
+
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	t.Setenv(httpapitest.ArtifactsDirEnv, "")
+
+	c, endpoint := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	t.Run("passed", func(t *testing.T) {
+		recorder := httpapitest.NewHARRecorder(t, "")
+		httpapitest.Request(t, c, http.MethodGet, endpoint, httpapitest.WithHARRecorder(recorder))
+	})
+	if dirs, _ := filepath.Glob(filepath.Join(tmp, "httpapitest-*")); len(dirs) != 0 {
+		t.Fatalf("got directories %v for passed test, want none", dirs)
+	}
+
+	tb := &failedTB{TB: t}
+	recorder := httpapitest.NewHARRecorder(tb, "")
+	httpapitest.Request(t, c, http.MethodGet, endpoint, httpapitest.WithHARRecorder(recorder))
+	tb.cleanup()
+
+	// the file must be preserved after the test is done
+	files, err := filepath.Glob(filepath.Join(tmp, "httpapitest-*", "TestNewHARRecorder_temporaryDirectory.har"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("got files %v, want one", files)
+	}
+	if want := "har file: " + files[0]; len(tb.logs) != 1 || tb.logs[0] != want {
+		t.Errorf("got logs %q, want %q", tb.logs, want)
+	}
+}
+
+// failedTB is a testing.TB of a failed test that runs cleanup functions when
+// the cleanup method is called.
+type failedTB struct {
+	testing.TB
+	cleanups []func()
+	logs     []string
+}
+
+func (t *failedTB) Helper() {}
+
+func (t *failedTB) Failed() bool { return true }
+
+func (t *failedTB) Cleanup(f func()) {
+	t.cleanups = append(t.cleanups, f)
+}
+
+func (t *failedTB) Logf(format string, args ...interface{}) {
+	t.logs = append(t.logs, fmt.Sprintf(format, args...))
+}
+
+func (t *failedTB) cleanup() {
+	for i := len(t.cleanups) - 1; i >= 0; i-- {
+		t.cleanups[i]()
+	}
+}
+
+func TestNewHARRecorder_redactHeaders(t *testing.T) {
+
+	c, endpoint := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	recorder := httpapitest.NewHARRecorder(t, filepath.Join(t.TempDir(), "test.har"), "X-Api-Key")
+	httpapitest.Request(t, c, http.MethodGet, endpoint,
+		httpapitest.WithHARRecorder(recorder),
+		httpapitest.WithRequestHeader("X-Api-Key", "secret"),
+	)
+
+	var buf bytes.Buffer
+	if _, err := recorder.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var h testHAR
+	if err := json.Unmarshal(buf.Bytes(), &h); err != nil {
+		t.Fatal(err)
+	}
+	if len(h.Log.Entries) != 1 {
+		t.Fatalf("got %v entries, want 1", len(h.Log.Entries))
+	}
+	if got := h.Log.Entries[0].Request.Headers; len(got) != 1 || got[0].Name != "X-Api-Key" || got[0].Value != "[REDACTED]" {
+		t.Errorf("got request headers %v", got)
+	}
+}
@@ -15,6 +15,10 @@ should validate response from the server:
 The HTTP request will be executed using the supplied client, and response
 checked in expected status code is returned, as well as with each configured
 option function.
+
+The Check function makes the request in the same way, but returns failed
+validations as AssertionError values instead of reporting them to testing.TB,
+so that it can be used outside of tests.
 */
 package httpapitest
 
@@ -27,8 +31,11 @@ import (
 	"mime/multipart"
 	"net/http"
 	"net/textproto"
+	"os"
+	"reflect"
 	"strconv"
 	"testing"
+	"time"
 )
 
 // Request is a testing helper function that makes an HTTP request using
@@ -39,89 +46,108 @@ import (
 func Request(t testing.TB, client *http.Client, method, url string, opts ...Option) {
 	t.Helper()
 
-	o := new(options)
-	for _, opt := range opts {
-		if err := opt.apply(o); err != nil {
-			t.Fatal(err)
-		}
-	}
-
-	req, err := http.NewRequest(method, url, o.requestBody)
-	if err != nil {
-		t.Fatal(err)
-	}
-	req.Header = o.requestHeaders
-	if o.ctx != nil {
-		req = req.WithContext(o.ctx)
-	}
-	resp, err := client.Do(req)
+	o, err := newOptions(opts)
 	if err != nil {
-		t.Fatal(err)
+		o.fatalOptions(t, method, url, err)
 	}
-	defer resp.Body.Close()
 
-	if o.responseCode != 0 {
-		if resp.StatusCode != o.responseCode {
-			t.Errorf("got response status %s, want %v %s", resp.Status, o.responseCode, http.StatusText(o.responseCode))
-		}
-	}
+	o.request(t, client, method, url)
+}
 
-	for key := range o.responseHeaders {
-		want := o.responseHeaders.Get(key)
-		got := resp.Header.Get(key)
-		if got != want {
-			t.Errorf("got header %q value %q, want %q", key, got, want)
-		}
+// fatalOptions records the error of options that failed to the report, if it
+// is set, as the request is not made, and fails the test.
+func (o *options) fatalOptions(t testing.TB, method, url string, err error) {
+	t.Helper()
+
+	o.reportResult(t.Name(), method, url, time.Now(), &result{err: err})
+	t.Fatal(err)
+}
+
+// request makes the request and reports its failures to testing.TB.
+func (o *options) request(t testing.TB, client *http.Client, method, url string) {
+	t.Helper()
+
+	if o.dump == nil {
+		o.dump = dumpOptionsFromEnv()
 	}
 
-	if o.expectedResponse != nil {
-		readerContentEqual(t, resp.Body, o.expectedResponse)
-		return
+	start := time.Now()
+	r := o.do(client, method, url)
+	if o.report != nil {
+		o.reportResult(t.Name(), method, url, start, r)
 	}
 
-	if o.expectedJSONResponse != nil {
-		got, err := io.ReadAll(resp.Body)
-		if err != nil {
-			t.Fatal(err)
+	if r.failed() && o.eventually != nil {
+		t.Logf("eventually: validation failed after %v attempts in %v", r.attempts, r.duration.Round(time.Millisecond))
+	}
+	for _, f := range r.failures {
+		t.Errorf("%s", f.Message)
+	}
+	if r.failed() {
+		for _, m := range r.exchange.retries {
+			t.Logf("%s", m)
 		}
-		got = bytes.TrimSpace(got)
-
-		want, err := json.Marshal(o.expectedJSONResponse)
-		if err != nil {
-			t.Fatal(err)
+		if o.dump != nil {
+			t.Logf("%s", o.dump.dump(&r.exchange))
 		}
-
-		if !bytes.Equal(got, want) {
-			t.Errorf("got json response %q, want %q", string(got), string(want))
+		if r.exchange.request != nil {
+			t.Logf("timing: %v", r.exchange.timing)
 		}
-		return
-	}
-
-	if o.unmarshalResponse != nil {
-		if err := json.NewDecoder(resp.Body).Decode(&o.unmarshalResponse); err != nil {
-			t.Fatal(err)
+		if o.curl != nil && r.exchange.request != nil {
+			cmd, err := o.curl.command(&r.exchange)
+			if err != nil {
+				t.Logf("curl command: %v", err)
+			} else {
+				t.Logf("%s", cmd)
+			}
 		}
-		return
 	}
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+}
 
-	if o.responseBody != nil {
-		got, err := io.ReadAll(resp.Body)
-		if err != nil {
-			t.Fatal(err)
-		}
-		*o.responseBody = got
-		return
+// RequestJSON is a testing helper function that makes an HTTP request in the
+// same way as the Request function, with the same options, and returns the
+// JSON-decoded response body as a value of type T. Options that validate the
+// response body in other ways, ExpectedResponse, ExpectedJSONResponse,
+// ExpectEvents and ExpectJSONLines, can not be used.
+func RequestJSON[T any](t testing.TB, client *http.Client, method, url string, opts ...Option) T {
+	t.Helper()
+
+	o, err := newOptions(opts)
+	if err != nil {
+		o.fatalOptions(t, method, url, err)
+	}
+	if err := o.responseBodyConsumed(); err != nil {
+		o.fatalOptions(t, method, url, fmt.Errorf("request json: %w", err))
+	}
+	var v T
+	if err := UnmarshalJSONResponse(&v).apply(o); err != nil {
+		o.fatalOptions(t, method, url, err)
 	}
 
-	if o.noResponseBody {
-		got, err := io.ReadAll(resp.Body)
-		if err != nil {
-			t.Fatal(err)
-		}
-		if len(got) > 0 {
-			t.Errorf("got response body %q, want none", string(got))
-		}
+	o.request(t, client, method, url)
+	return v
+}
+
+// responseBodyConsumed returns an error if any option consumes the response
+// body before it can be decoded by the UnmarshalJSONResponse option.
+func (o *options) responseBodyConsumed() error {
+	var name string
+	switch {
+	case o.expectedResponse != nil:
+		name = "ExpectedResponse"
+	case o.expectedJSONResponse != nil:
+		name = "ExpectedJSONResponse"
+	case o.events != nil:
+		name = "ExpectEvents"
+	case o.jsonLines != nil:
+		name = "ExpectJSONLines"
+	default:
+		return nil
 	}
+	return fmt.Errorf("response body is consumed by the %s option", name)
 }
 
 // WithContext sets a context to the request made by the Request function.
@@ -132,6 +158,17 @@ func WithContext(ctx context.Context) Option {
 	})
 }
 
+// WithTimeout sets a timeout to the request made by the Request function,
+// including reading of the response body, by deriving a context from the one
+// set with the WithContext option. With the Eventually option, the timeout is
+// applied to every attempt.
+func WithTimeout(timeout time.Duration) Option {
+	return optionFunc(func(o *options) error {
+		o.timeout = timeout
+		return nil
+	})
+}
+
 // WithRequestBody writes a request body to the request made by the Request
 // function.
 func WithRequestBody(body io.Reader) Option {
@@ -232,6 +269,27 @@ func ExpectResponseHeader(key, value string) Option {
 	})
 }
 
+// ExpectMaxLatency validates that the response from the request in the Request
+// function, including its body, is received within the duration. With the
+// ExpectEvents and ExpectJSONLines options, the response body is read as a
+// stream after the validation, so only the time until the response headers
+// are received is validated.
+func ExpectMaxLatency(d time.Duration) Option {
+	return optionFunc(func(o *options) error {
+		o.maxLatency = d
+		return nil
+	})
+}
+
+// ExpectMaxTimeToFirstByte validates that the response headers from the
+// request in the Request function are received within the duration.
+func ExpectMaxTimeToFirstByte(d time.Duration) Option {
+	return optionFunc(func(o *options) error {
+		o.maxTimeToFirstByte = d
+		return nil
+	})
+}
+
 // ExpectedResponse validates that the response from the request in the
 // Request function matches the date rad from the reader.
 func ExpectedResponse(r io.Reader) Option {
@@ -250,15 +308,64 @@ func ExpectedJSONResponse(response interface{}) Option {
 	})
 }
 
+// ExpectJSONSchema validates that the response body from the request in the
+// Request function is a JSON value that is valid against the provided JSON
+// Schema draft 2020-12 document. Every schema violation is reported with the
+// JSON pointer to the invalid value. Format and content keywords are treated
+// only as annotations and regular expressions are evaluated with the Go regexp
+// syntax.
+func ExpectJSONSchema(schema []byte) Option {
+	return optionFunc(func(o *options) error {
+		s, err := compileJSONSchema(schema)
+		if err != nil {
+			return err
+		}
+		o.jsonSchema = s
+		return nil
+	})
+}
+
+// ExpectJSONSchemaFile validates the response body in the same way as
+// ExpectJSONSchema option, with the schema document read from the file.
+func ExpectJSONSchemaFile(filename string) Option {
+	return optionFunc(func(o *options) error {
+		schema, err := os.ReadFile(filename)
+		if err != nil {
+			return fmt.Errorf("read json schema: %w", err)
+		}
+		s, err := compileJSONSchema(schema)
+		if err != nil {
+			return fmt.Errorf("%s: %w", filename, err)
+		}
+		o.jsonSchema = s
+		return nil
+	})
+}
+
 // UnmarshalJSONResponse unmarshals response body from the request in the
-// Request function to the provided response. Response must be a pointer.
+// Request function to the provided response. Response must be a non-nil
+// pointer.
 func UnmarshalJSONResponse(response interface{}) Option {
 	return optionFunc(func(o *options) error {
+		if v := reflect.ValueOf(response); v.Kind() != reflect.Pointer || v.IsNil() {
+			return fmt.Errorf("unmarshal json response: want non-nil pointer, got %T", response)
+		}
 		o.unmarshalResponse = response
 		return nil
 	})
 }
 
+// StrictJSONDecoding makes UnmarshalJSONResponse option and RequestJSON
+// function fail if the response contains object keys that do not match any
+// field in the destination value or if there is any data after the first JSON
+// value in the response body.
+func StrictJSONDecoding() Option {
+	return optionFunc(func(o *options) error {
+		o.strictJSON = true
+		return nil
+	})
+}
+
 // PutResponseBody replaces the data in the provided byte slice with the
 // data from the response body of the request in the Request function.
 //
@@ -286,15 +393,63 @@ func ExpectNoResponseBody() Option {
 
 type options struct {
 	ctx                  context.Context
+	timeout              time.Duration
+	maxLatency           time.Duration
+	maxTimeToFirstByte   time.Duration
 	responseCode         int
 	requestBody          io.Reader
 	requestHeaders       http.Header
 	responseHeaders      http.Header
 	expectedResponse     io.Reader
 	expectedJSONResponse interface{}
+	jsonSchema           *jsonSchema
+	jsonPaths            []jsonPathExpectation
+	jsonSubsets          []interface{}
+	openAPI              *OpenAPI
+	vars                 *Vars
+	eventually           *eventuallyOptions
+	retry                *retryOptions
+	events               *eventsOptions
+	jsonLines            *jsonLinesOptions
+	timing               *Timing
+	report               *Report
+	name                 string
+	captures             []capture
+	har                  *HARRecorder
+	dump                 *dumpOptions
+	curl                 *curlOptions
 	unmarshalResponse    interface{}
+	strictJSON           bool
 	responseBody         *[]byte
 	noResponseBody       bool
+	keepBody             bool
+}
+
+// keepRequestBody returns true if the request body data is needed by any
+// option after the request is sent, if variables are interpolated in it or if
+// it may be sent again on retry.
+func (o *options) keepRequestBody() bool {
+	return o.openAPI != nil || o.har != nil || o.dump != nil || o.curl != nil ||
+		o.vars != nil || o.retry != nil
+}
+
+// keepResponseBody returns true if the response body data must be read before
+// any other validation as it is needed by more than one option.
+func (o *options) keepResponseBody() bool {
+	return o.jsonSchema != nil || o.openAPI != nil || o.har != nil || o.dump != nil ||
+		o.jsonPaths != nil || o.jsonSubsets != nil || o.captures != nil ||
+		o.maxLatency > 0 || o.keepBody
+}
+
+// exchange holds the request made by the Request function and its response,
+// with their bodies if they are kept by options.
+type exchange struct {
+	request      *http.Request
+	requestBody  []byte
+	response     *http.Response
+	responseBody []byte
+	timing       Timing
+	retries      []string
 }
 
 type Option interface {
@@ -303,37 +458,3 @@ type Option interface {
 type optionFunc func(*options) error
 
 func (f optionFunc) apply(r *options) error { return f(r) }
-
-func readerContentEqual(t testing.TB, r1, r2 io.Reader) {
-	t.Helper()
-
-	const bufSize = 128
-
-	buf1 := make([]byte, bufSize)
-	buf2 := make([]byte, bufSize)
-
-	var cursor int
-	for {
-		n1, err := r1.Read(buf1)
-		buf1 = buf1[:n1]
-		if err != nil && err != io.EOF {
-			t.Fatalf("read input data at position %v: %v", cursor, err)
-		}
-		n2, err := r2.Read(buf2)
-		buf2 = buf2[:n2]
-		if err != nil && err != io.EOF {
-			t.Fatalf("read validation data at position %v: %v", cursor, err)
-		}
-
-		if !bytes.Equal(buf1, buf2) {
-			t.Errorf("data not equal at position %v: got %q, want %q", cursor, string(buf1), string(buf2))
-			return
-		}
-
-		if err == io.EOF {
-			break
-		}
-
-		cursor += n1
-	}
-}
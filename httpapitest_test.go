@@ -19,6 +19,7 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"resenje.org/httpapitest"
 )
@@ -348,6 +349,116 @@ func TestUnmarshalJSONResponse(t *testing.T) {
 	}
 }
 
+func TestUnmarshalJSONResponse_nonPointer(t *testing.T) {
+
+	c, endpoint := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		respondJSON(w, http.StatusOK, "text")
+	}))
+
+	var r jsonStatusResponse
+	assert(t, "", "unmarshal json response: want non-nil pointer, got httpapitest_test.jsonStatusResponse", func(m *mock) {
+		httpapitest.Request(m, c, http.MethodGet, endpoint,
+			httpapitest.UnmarshalJSONResponse(r),
+		)
+	})
+
+	assert(t, "", "unmarshal json response: want non-nil pointer, got *httpapitest_test.jsonStatusResponse", func(m *mock) {
+		httpapitest.Request(m, c, http.MethodGet, endpoint,
+			httpapitest.UnmarshalJSONResponse((*jsonStatusResponse)(nil)),
+		)
+	})
+}
+
+func TestStrictJSONDecoding(t *testing.T) {
+
+	c, endpoint := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/unknown":
+			fmt.Fprintln(w, `{"message":"text","unknown":true}`)
+		case "/trailing":
+			fmt.Fprintln(w, `{"message":"text"}`)
+			fmt.Fprintln(w, `{"message":"more"}`)
+		default:
+			fmt.Fprintln(w, `{"message":"text"}`)
+		}
+	}))
+
+	var r jsonStatusResponse
+	assert(t, "", "", func(m *mock) {
+		httpapitest.Request(m, c, http.MethodGet, endpoint,
+			httpapitest.UnmarshalJSONResponse(&r),
+			httpapitest.StrictJSONDecoding(),
+		)
+	})
+	if r.Message != "text" {
+		t.Errorf("got message %q, want %q", r.Message, "text")
+	}
+
+	assert(t, "", "", func(m *mock) {
+		httpapitest.Request(m, c, http.MethodGet, endpoint+"/unknown",
+			httpapitest.UnmarshalJSONResponse(&r),
+		)
+	})
+
+	assert(t, "", `json: unknown field "unknown"`, func(m *mock) {
+		httpapitest.Request(m, c, http.MethodGet, endpoint+"/unknown",
+			httpapitest.UnmarshalJSONResponse(&r),
+			httpapitest.StrictJSONDecoding(),
+		)
+	})
+
+	assert(t, "", "unexpected data after json response value", func(m *mock) {
+		httpapitest.RequestJSON[jsonStatusResponse](m, c, http.MethodGet, endpoint+"/trailing",
+			httpapitest.StrictJSONDecoding(),
+		)
+	})
+}
+
+func TestRequestJSON(t *testing.T) {
+
+	message := "text"
+
+	c, endpoint := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		respondJSON(w, http.StatusCreated, message)
+	}))
+
+	var r jsonStatusResponse
+	assert(t, "", "", func(m *mock) {
+		r = httpapitest.RequestJSON[jsonStatusResponse](m, c, http.MethodGet, endpoint,
+			httpapitest.ExpectStatus(http.StatusCreated),
+		)
+	})
+	if r.Message != message {
+		t.Errorf("got message %q, want %q", r.Message, message)
+	}
+	if r.Code != http.StatusCreated {
+		t.Errorf("got code %v, want %v", r.Code, http.StatusCreated)
+	}
+}
+
+func TestRequestJSON_consumedBody(t *testing.T) {
+
+	c, endpoint := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		respondJSON(w, http.StatusOK, "text")
+	}))
+
+	for _, tc := range []struct {
+		name string
+		opt  httpapitest.Option
+	}{
+		{name: "ExpectedResponse", opt: httpapitest.ExpectedResponse(strings.NewReader("text"))},
+		{name: "ExpectedJSONResponse", opt: httpapitest.ExpectedJSONResponse("text")},
+		{name: "ExpectEvents", opt: httpapitest.ExpectEvents(time.Second)},
+		{name: "ExpectJSONLines", opt: httpapitest.ExpectJSONLines()},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert(t, "", "request json: response body is consumed by the "+tc.name+" option", func(m *mock) {
+				httpapitest.RequestJSON[jsonStatusResponse](m, c, http.MethodGet, endpoint, tc.opt)
+			})
+		})
+	}
+}
+
 func TestPutResponseBody(t *testing.T) {
 
 	wantBody := []byte("somebody")
@@ -391,6 +502,20 @@ func TestExpectNoResponseBody(t *testing.T) {
 	})
 }
 
+// withoutTiming returns logs without the timing of the failed request,
+// reporting an error if it is not logged.
+func withoutTiming(t *testing.T, logs []string) []string {
+	t.Helper()
+
+	for i, l := range logs {
+		if strings.HasPrefix(l, "timing: dns ") {
+			return append(logs[:i:i], logs[i+1:]...)
+		}
+	}
+	t.Errorf("got no timing in logs %q", logs)
+	return logs
+}
+
 func newClient(t *testing.T, handler http.Handler) (c *http.Client, endpoint string) {
 	t.Helper()
 
@@ -404,44 +529,47 @@ func newClient(t *testing.T, handler http.Handler) (c *http.Client, endpoint str
 func assert(t *testing.T, wantError, wantFatal string, f func(m *mock)) {
 	t.Helper()
 
-	defer func() {
-		if v := recover(); v != nil {
-			if err, ok := v.(error); ok && errors.Is(err, errFailed) {
-				return // execution of the goroutine is stopped by a mock Fatal function
-			}
-			t.Fatalf("panic: %v", v)
-		}
-	}()
-
 	m := &mock{
 		wantError: wantError,
 		wantFatal: wantFatal,
 	}
 
-	f(m)
+	defer func() {
+		if v := recover(); v != nil {
+			// execution of the goroutine can be stopped only by a mock Fatal
+			// function
+			if err, ok := v.(error); !ok || !errors.Is(err, errFailed) {
+				t.Fatalf("panic: %v", v)
+			}
+		}
 
-	if !m.isHelper { // Request function is tested and it must be always a helper
-		t.Error("not a helper function")
-	}
+		if !m.isHelper { // Request function is tested and it must be always a helper
+			t.Error("not a helper function")
+		}
 
-	if m.gotError != m.wantError {
-		t.Errorf("got error %q, want %q", m.gotError, m.wantError)
-	}
+		if m.gotError != m.wantError {
+			t.Errorf("got error %q, want %q", m.gotError, m.wantError)
+		}
 
-	if m.gotFatal != m.wantFatal {
-		t.Errorf("got error %v, want %v", m.gotFatal, m.wantFatal)
-	}
+		if m.gotFatal != m.wantFatal {
+			t.Errorf("got fatal %q, want %q", m.gotFatal, m.wantFatal)
+		}
+	}()
+
+	f(m)
 }
 
-// mock provides the same interface as testing.TB with overridden Errorf, Fatal
-// and Helper methods.
+// mock provides the same interface as testing.TB with overridden Errorf, Fatal,
+// Logf, Name and Helper methods.
 type mock struct {
 	testing.TB
 	isHelper  bool
 	gotError  string
+	gotErrors []string
 	wantError string
 	gotFatal  string
 	wantFatal string
+	gotLogs   []string
 }
 
 func (m *mock) Helper() {
@@ -450,6 +578,15 @@ func (m *mock) Helper() {
 
 func (m *mock) Errorf(format string, args ...interface{}) {
 	m.gotError = fmt.Sprintf(format, args...)
+	m.gotErrors = append(m.gotErrors, m.gotError)
+}
+
+func (m *mock) Name() string {
+	return "mock"
+}
+
+func (m *mock) Logf(format string, args ...interface{}) {
+	m.gotLogs = append(m.gotLogs, fmt.Sprintf(format, args...))
 }
 
 func (m *mock) Fatal(args ...interface{}) {
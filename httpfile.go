@@ -0,0 +1,234 @@
+// Copyright (c) 2023, Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httpapitest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// parseHTTPFile parses test cases from a file in the .http (REST Client)
+// format. Requests are separated by lines starting with ###, followed by an
+// optional name. File variables are defined with lines in form @name = value
+// and referenced with {{name}}. References to variables that are not defined
+// in the file are left to be interpolated when the request is made. Lines
+// starting with # or // before the request line are comments, and the ones in
+// form @expect are assertions and in form @capture are variable captures:
+//
+//	# @expect status 200
+//	# @expect header Content-Type application/json
+//	# @expect json $.id 1
+//	# @expect json {"name": "test"}
+//	# @expect body plain text
+//	# @expect nobody
+//	# @capture id json $.id
+//	# @capture location header Location
+//	# @capture session cookie session
+func parseHTTPFile(data []byte) ([]fileCase, error) {
+	vars := make(map[string]string)
+	expand := func(s string) string {
+		return varReference.ReplaceAllStringFunc(s, func(m string) string {
+			if v, ok := vars[varReference.FindStringSubmatch(m)[1]]; ok {
+				return v
+			}
+			return m
+		})
+	}
+
+	var cases []fileCase
+	var c *fileCase
+	var body []string
+	var inHeaders, inBody bool
+	finish := func() {
+		if c == nil {
+			return
+		}
+		if b := strings.TrimRight(strings.Join(body, "\n"), " \t\r\n"); b != "" {
+			c.Request.BodyText = &b
+		}
+		if c.Request.Path != "" {
+			cases = append(cases, *c)
+		}
+		c = nil
+		body = nil
+		inHeaders = false
+		inBody = false
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 1<<20)
+	var n int
+	for scanner.Scan() {
+		n++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		trimmed := strings.TrimSpace(line)
+
+		if strings.HasPrefix(trimmed, "###") {
+			finish()
+			c = &fileCase{Name: strings.TrimSpace(strings.TrimLeft(trimmed, "#"))}
+			continue
+		}
+		if c == nil {
+			c = new(fileCase)
+		}
+
+		if inBody {
+			v := expand(line)
+			body = append(body, v)
+			continue
+		}
+
+		if inHeaders {
+			if trimmed == "" {
+				inHeaders = false
+				inBody = true
+				continue
+			}
+			if strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "//") {
+				continue
+			}
+			if strings.HasPrefix(trimmed, "?") || strings.HasPrefix(trimmed, "&") {
+				v := expand(trimmed)
+				c.Request.Path += v
+				continue
+			}
+			i := strings.IndexByte(line, ':')
+			if i <= 0 {
+				return nil, fmt.Errorf("line %v: invalid header %q", n, line)
+			}
+			v := expand(strings.TrimSpace(line[i+1:]))
+			if c.Request.Headers == nil {
+				c.Request.Headers = make(map[string]string)
+			}
+			c.Request.Headers[strings.TrimSpace(line[:i])] = v
+			continue
+		}
+
+		switch {
+		case trimmed == "":
+		case strings.HasPrefix(trimmed, "@"):
+			i := strings.IndexByte(trimmed, '=')
+			if i < 0 {
+				return nil, fmt.Errorf("line %v: invalid variable definition %q", n, trimmed)
+			}
+			v := expand(strings.TrimSpace(trimmed[i+1:]))
+			vars[strings.TrimSpace(trimmed[1:i])] = v
+		case strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "//"):
+			comment := strings.TrimSpace(strings.TrimLeft(trimmed, "#/"))
+			if err := c.parseHTTPFileDirective(n, comment, expand); err != nil {
+				return nil, err
+			}
+		default:
+			v := expand(trimmed)
+			fields := strings.Fields(v)
+			if len(fields) > 1 && strings.HasPrefix(fields[len(fields)-1], "HTTP/") {
+				fields = fields[:len(fields)-1]
+			}
+			switch len(fields) {
+			case 1:
+				c.Request.Path = fields[0]
+			case 2:
+				c.Request.Method = fields[0]
+				c.Request.Path = fields[1]
+			default:
+				return nil, fmt.Errorf("line %v: invalid request line %q", n, trimmed)
+			}
+			inHeaders = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	finish()
+	return cases, nil
+}
+
+// parseHTTPFileDirective parses @name, @capture and @expect comments.
+func (c *fileCase) parseHTTPFileDirective(line int, comment string, expand func(string) string) error {
+	switch {
+	case strings.HasPrefix(comment, "@name "):
+		c.Name = strings.TrimSpace(strings.TrimPrefix(comment, "@name "))
+		return nil
+	case strings.HasPrefix(comment, "@capture "):
+		return c.parseHTTPFileCapture(line, strings.TrimSpace(strings.TrimPrefix(comment, "@capture ")))
+	case !strings.HasPrefix(comment, "@expect "):
+		return nil
+	}
+	comment = expand(strings.TrimSpace(strings.TrimPrefix(comment, "@expect ")))
+	kind, arg, _ := strings.Cut(comment, " ")
+	arg = strings.TrimSpace(arg)
+	switch kind {
+	case "status":
+		code, err := strconv.Atoi(arg)
+		if err != nil || code < 100 || code > 999 {
+			return fmt.Errorf("line %v: invalid status %q", line, arg)
+		}
+		c.Expect.Status = code
+	case "header":
+		name, value, _ := strings.Cut(arg, " ")
+		if name == "" {
+			return fmt.Errorf("line %v: missing header name", line)
+		}
+		if c.Expect.Headers == nil {
+			c.Expect.Headers = make(map[string]string)
+		}
+		c.Expect.Headers[http.CanonicalHeaderKey(strings.TrimSuffix(name, ":"))] = strings.TrimSpace(value)
+	case "json":
+		if strings.HasPrefix(arg, "$") {
+			path, value, _ := strings.Cut(arg, " ")
+			value = strings.TrimSpace(value)
+			if !json.Valid([]byte(value)) {
+				return fmt.Errorf("line %v: invalid json value %q", line, value)
+			}
+			if c.Expect.JSONPath == nil {
+				c.Expect.JSONPath = make(map[string]json.RawMessage)
+			}
+			c.Expect.JSONPath[path] = json.RawMessage(value)
+			break
+		}
+		if !json.Valid([]byte(arg)) {
+			return fmt.Errorf("line %v: invalid json value %q", line, arg)
+		}
+		c.Expect.JSON = json.RawMessage(arg)
+	case "body":
+		c.Expect.Body = &arg
+	case "nobody":
+		c.Expect.NoBody = true
+	default:
+		return fmt.Errorf("line %v: unknown assertion %q", line, kind)
+	}
+	return nil
+}
+
+// parseHTTPFileCapture parses the @capture comment arguments.
+func (c *fileCase) parseHTTPFileCapture(line int, arg string) error {
+	fields := strings.Fields(arg)
+	if len(fields) != 3 {
+		return fmt.Errorf("line %v: invalid capture %q", line, arg)
+	}
+	name, kind, key := fields[0], fields[1], fields[2]
+	var m *map[string]string
+	switch kind {
+	case "json":
+		m = &c.Capture.JSONPath
+	case "header":
+		m = &c.Capture.Headers
+	case "cookie":
+		m = &c.Capture.Cookies
+	default:
+		return fmt.Errorf("line %v: unknown capture %q", line, kind)
+	}
+	if *m == nil {
+		*m = make(map[string]string)
+	}
+	(*m)[name] = key
+	return nil
+}
@@ -0,0 +1,86 @@
+// Copyright (c) 2023, Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httpapitest_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+
+	"resenje.org/httpapitest"
+)
+
+func TestRunFiles_http(t *testing.T) {
+	var mu sync.Mutex
+	var got []string
+	c, endpoint := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		got = append(got, r.Method+" "+r.URL.RequestURI()+" "+r.Header.Get("Authorization")+" "+string(b))
+		mu.Unlock()
+		switch r.Method {
+		case http.MethodPost:
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write(b)
+		case http.MethodDelete:
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"id": 1, "roles": []string{"admin", "user"}})
+		}
+	}))
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"users.http": `@host = ` + endpoint + `
+@token = secret
+@auth = Bearer {{token}}
+
+### get user
+# @expect status 200
+# @expect header Content-Type application/json
+// @expect json $.roles[0] "admin"
+# @expect json {"id": 1}
+GET {{host}}/users/1 HTTP/1.1
+Authorization: {{auth}}
+
+###
+# @name create
+# @expect status 201
+# @expect json $.name "test"
+# @capture name json $.name
+POST /users
+    ?limit=1
+    &offset=2
+Content-Type: application/json
+
+{
+    "name": "test"
+}
+
+
+###
+# @expect status 204
+# @expect nobody
+DELETE /users/{{name}}
+`,
+	})
+
+	httpapitest.RunFiles(t, c, endpoint, dir)
+
+	want := []string{
+		"GET /users/1 Bearer secret ",
+		"POST /users?limit=1&offset=2  {\n    \"name\": \"test\"\n}",
+		"DELETE /users/test  ",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got requests %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright (c) 2023, Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httpapitest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// JSONMatcher validates a decoded JSON value, with numbers as json.Number. It
+// returns an error that describes the failed validation.
+type JSONMatcher func(v interface{}) error
+
+// MatchJSON validates that the JSON value is equal to the JSON-encoded value
+// provided here.
+func MatchJSON(value interface{}) JSONMatcher {
+	want, err := normalizeJSON(value)
+	return func(v interface{}) error {
+		if err != nil {
+			return fmt.Errorf("json: %w", err)
+		}
+		if !jsonEqual(v, want) {
+			return AssertionError{
+				Kind:     AssertionJSON,
+				Path:     "$",
+				Expected: jsonString(want),
+				Actual:   jsonString(v),
+				Message:  fmt.Sprintf("got json %s, want %s", jsonString(v), jsonString(want)),
+			}
+		}
+		return nil
+	}
+}
+
+// MatchJSONPath validates that the value selected by the JSONPath expression
+// from the JSON value is equal to the JSON-encoded value provided here, as with
+// the ExpectJSONPath option.
+func MatchJSONPath(expression string, value interface{}) JSONMatcher {
+	p, err := parseJSONPath(expression)
+	if err == nil {
+		value, err = normalizeJSON(value)
+	}
+	return func(v interface{}) error {
+		if err != nil {
+			return fmt.Errorf("json path %q: %w", expression, err)
+		}
+		got, ok := p.value(v)
+		if !ok {
+			return jsonMissingError(expression, value)
+		}
+		if !jsonEqual(got, value) {
+			return jsonValueError(expression, got, value)
+		}
+		return nil
+	}
+}
+
+// MatchJSONSubset validates that the JSON value contains the JSON-encoded value
+// provided here, as with the ExpectJSONSubset option. Only the first
+// difference is returned.
+func MatchJSONSubset(value interface{}) JSONMatcher {
+	want, err := normalizeJSON(value)
+	return func(v interface{}) error {
+		if err != nil {
+			return fmt.Errorf("json subset: %w", err)
+		}
+		if failures := jsonSubsetErrors(v, want, "$"); len(failures) > 0 {
+			return failures[0]
+		}
+		return nil
+	}
+}
+
+// maxJSONLinesFailures is the number of records with failed validations that
+// are reported.
+const maxJSONLinesFailures = 10
+
+type jsonLinesOptions struct {
+	matchers []JSONMatcher
+	min      int
+	max      int
+}
+
+func (o *options) jsonLinesOptions() *jsonLinesOptions {
+	if o.jsonLines == nil {
+		o.jsonLines = &jsonLinesOptions{max: -1}
+	}
+	return o.jsonLines
+}
+
+// ExpectJSONLines validates that the response body of the request made by the
+// Request function is a stream of JSON values, such as newline-delimited JSON,
+// and that every value is matched by all provided matchers. The response body
+// is decoded incrementally, without buffering it, so options that require the
+// whole response body, such as ExpectJSONSchema, ExpectJSONPath or
+// CaptureJSONPath, can not be used together with this option, and the
+// WithOpenAPI option does not validate the response body against its schema.
+// Failures of the first ten records that are not matched are reported.
+func ExpectJSONLines(matchers ...JSONMatcher) Option {
+	return optionFunc(func(o *options) error {
+		l := o.jsonLinesOptions()
+		l.matchers = append(l.matchers, matchers...)
+		return nil
+	})
+}
+
+// ExpectJSONLinesCount validates that the response body of the request made by
+// the Request function is a stream of at least min and at most max JSON
+// values, as with the ExpectJSONLines option. If max is negative, the number
+// of values is not limited.
+func ExpectJSONLinesCount(min, max int) Option {
+	return optionFunc(func(o *options) error {
+		if min < 0 {
+			return errors.New("json lines: minimal count must not be negative")
+		}
+		if max >= 0 && max < min {
+			return errors.New("json lines: maximal count must not be less than the minimal count")
+		}
+		l := o.jsonLinesOptions()
+		l.min = min
+		l.max = max
+		return nil
+	})
+}
+
+// validate decodes JSON values from the response body one by one and matches
+// them.
+func (l *jsonLinesOptions) validate(body io.Reader) ([]AssertionError, error) {
+	var failures []AssertionError
+	dec := json.NewDecoder(body)
+	dec.UseNumber()
+	var count, failed int
+	for {
+		var v interface{}
+		if err := dec.Decode(&v); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			var syntaxErr *json.SyntaxError
+			if !errors.As(err, &syntaxErr) && !errors.Is(err, io.ErrUnexpectedEOF) {
+				return failures, err
+			}
+			failures = append(failures, AssertionError{
+				Kind:    AssertionJSON,
+				Path:    "record " + strconv.Itoa(count+1),
+				Message: fmt.Sprintf("record %v: got invalid json: %v", count+1, err),
+			})
+			return failures, nil
+		}
+		count++
+
+		var recordFailed bool
+		for _, match := range l.matchers {
+			err := match(v)
+			if err == nil {
+				continue
+			}
+			if !recordFailed {
+				recordFailed = true
+				failed++
+			}
+			if failed > maxJSONLinesFailures {
+				break
+			}
+			var e AssertionError
+			if !errors.As(err, &e) {
+				e = AssertionError{Kind: AssertionJSON, Message: err.Error()}
+			}
+			e.Message = fmt.Sprintf("record %v: %s", count, e.Message)
+			failures = append(failures, e)
+		}
+	}
+	if failed > maxJSONLinesFailures {
+		failures = append(failures, AssertionError{
+			Kind:    AssertionJSON,
+			Message: fmt.Sprintf("got %v records with failed validation, only first %v are reported", failed, maxJSONLinesFailures),
+		})
+	}
+	if count < l.min || (l.max >= 0 && count > l.max) {
+		want := fmt.Sprintf("at least %v", l.min)
+		switch {
+		case l.min == l.max:
+			want = strconv.Itoa(l.min)
+		case l.max >= 0:
+			want = fmt.Sprintf("between %v and %v", l.min, l.max)
+		}
+		failures = append(failures, AssertionError{
+			Kind:     AssertionJSON,
+			Expected: want,
+			Actual:   strconv.Itoa(count),
+			Message:  fmt.Sprintf("got %v json records, want %s", count, want),
+		})
+	}
+	return failures, nil
+}
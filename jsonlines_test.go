@@ -0,0 +1,228 @@
+// Copyright (c) 2023, Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httpapitest_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"resenje.org/httpapitest"
+)
+
+func TestExpectJSONLines(t *testing.T) {
+
+	c, endpoint := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		if r.URL.Path == "/invalid" {
+			fmt.Fprint(w, "{\"id\":1}\n{\"id\":\n")
+			return
+		}
+		for i := 1; i <= 25; i++ {
+			fmt.Fprintf(w, "{\"id\":%v,\"type\":\"row\",\"tags\":[\"a\"]}\n", i)
+		}
+	}))
+
+	assert(t, "", "", func(m *mock) {
+		httpapitest.Request(m, c, http.MethodGet, endpoint,
+			httpapitest.ExpectStatus(http.StatusOK),
+			httpapitest.ExpectJSONLines(
+				httpapitest.MatchJSONPath("$.type", "row"),
+				httpapitest.MatchJSONSubset(map[string]interface{}{"tags": []string{"a"}}),
+			),
+			httpapitest.ExpectJSONLinesCount(25, 25),
+		)
+	})
+
+	var ids []int
+	assert(t, "", "", func(m *mock) {
+		httpapitest.Request(m, c, http.MethodGet, endpoint,
+			httpapitest.ExpectJSONLines(func(v interface{}) error {
+				id, err := v.(map[string]interface{})["id"].(json.Number).Int64()
+				ids = append(ids, int(id))
+				return err
+			}),
+		)
+	})
+	if len(ids) != 25 || ids[0] != 1 || ids[24] != 25 {
+		t.Errorf("got ids %v", ids)
+	}
+
+	for _, tc := range []struct {
+		name string
+		path string
+		opts []httpapitest.Option
+		want string
+	}{
+		{
+			name: "count",
+			opts: []httpapitest.Option{httpapitest.ExpectJSONLinesCount(30, -1)},
+			want: "got 25 json records, want at least 30",
+		},
+		{
+			name: "count range",
+			opts: []httpapitest.Option{httpapitest.ExpectJSONLinesCount(10, 20)},
+			want: "got 25 json records, want between 10 and 20",
+		},
+		{
+			name: "count exact",
+			opts: []httpapitest.Option{httpapitest.ExpectJSONLinesCount(10, 10)},
+			want: "got 25 json records, want 10",
+		},
+		{
+			name: "json",
+			opts: []httpapitest.Option{httpapitest.ExpectJSONLines(
+				httpapitest.MatchJSON(map[string]interface{}{"id": 1, "type": "row", "tags": []string{"a"}}),
+			)},
+			want: "got 24 records with failed validation, only first 10 are reported",
+		},
+		{
+			name: "custom",
+			opts: []httpapitest.Option{httpapitest.ExpectJSONLines(
+				func(v interface{}) error {
+					if fmt.Sprint(v.(map[string]interface{})["id"]) == "3" {
+						return errors.New("unexpected id")
+					}
+					return nil
+				},
+				httpapitest.MatchJSONPath("$.type", "row"),
+			), httpapitest.ExpectJSONLinesCount(25, 25)},
+			want: "record 3: unexpected id",
+		},
+		{
+			name: "invalid",
+			path: "/invalid",
+			opts: []httpapitest.Option{httpapitest.ExpectJSONLinesCount(2, 2)},
+			want: "record 2: got invalid json: unexpected EOF",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert(t, tc.want, "", func(m *mock) {
+				httpapitest.Request(m, c, http.MethodGet, endpoint+tc.path, tc.opts...)
+			})
+		})
+	}
+
+	failures, err := httpapitest.Check(c, http.MethodGet, endpoint+"/invalid",
+		httpapitest.ExpectJSONLines(httpapitest.MatchJSONPath("$.id", 1)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []httpapitest.AssertionError{{
+		Kind:    httpapitest.AssertionJSON,
+		Path:    "record 2",
+		Message: "record 2: got invalid json: unexpected EOF",
+	}}
+	if fmt.Sprint(failures) != fmt.Sprint(want) {
+		t.Errorf("got failures %v, want %v", failures, want)
+	}
+
+	failures, err = httpapitest.Check(c, http.MethodGet, endpoint,
+		httpapitest.ExpectJSONLines(httpapitest.MatchJSONPath("$.id", 2)),
+		httpapitest.ExpectJSONLinesCount(25, 25),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failures) != 11 {
+		t.Fatalf("got %v failures, want 11", len(failures))
+	}
+	if got, want := failures[0], (httpapitest.AssertionError{
+		Kind:     httpapitest.AssertionJSON,
+		Path:     "$.id",
+		Expected: "2",
+		Actual:   "1",
+		Message:  `record 1: got json value at "$.id" 1, want 2`,
+	}); got != want {
+		t.Errorf("got failure %+v, want %+v", got, want)
+	}
+}
+
+func TestExpectJSONLines_wholeBodyOptions(t *testing.T) {
+
+	for _, tc := range append(wholeBodyOptions(), struct {
+		name string
+		opt  httpapitest.Option
+	}{"ExpectJSONLines", httpapitest.ExpectEvents(time.Second)}) {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := httpapitest.Check(http.DefaultClient, http.MethodGet, "http://localhost",
+				httpapitest.ExpectJSONLines(),
+				httpapitest.WithVars(new(httpapitest.Vars)),
+				tc.opt,
+			)
+			stream := "ExpectJSONLines"
+			if tc.name == "ExpectJSONLines" {
+				stream = "ExpectEvents"
+			}
+			want := "response body is streamed by the " + stream + " option and can not be used by the " + tc.name + " option"
+			if err == nil || err.Error() != want {
+				t.Errorf("got error %v, want %v", err, want)
+			}
+		})
+	}
+}
+
+func TestExpectJSONLines_streamedBody(t *testing.T) {
+
+	doc, err := httpapitest.LoadOpenAPI([]byte(`{
+		"openapi": "3.1.0",
+		"paths": {
+			"/lines": {"get": {"responses": {"200": {"description": "lines", "content": {"application/json": {"schema": {"type": "object"}}}}}}}
+		}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, endpoint := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, "{\"id\":1}\n{\"id\":2}\n")
+	}))
+
+	var recorder httpapitest.HARRecorder
+	failures, err := httpapitest.Check(c, http.MethodGet, endpoint+"/lines",
+		httpapitest.WithOpenAPI(doc),
+		httpapitest.WithHARRecorder(&recorder),
+		httpapitest.ExpectJSONLines(httpapitest.MatchJSONPath("$.id", 1)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range failures {
+		got = append(got, f.Message)
+	}
+	want := []string{
+		"openapi operation GET /lines: response body: streamed response body is not validated against the schema",
+		`record 2: got json value at "$.id" 2, want 1`,
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got failures %q, want %q", got, want)
+	}
+
+	var buf bytes.Buffer
+	if _, err := recorder.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"text": "{\"id\":1}\n{\"id\":2}\n"`) {
+		t.Errorf("got no response body in har %s", buf.String())
+	}
+
+	m := new(mock)
+	httpapitest.Request(m, c, http.MethodGet, endpoint+"/lines",
+		httpapitest.WithDumpOnFailure(0),
+		httpapitest.ExpectJSONLinesCount(3, 3),
+	)
+	if logs := strings.Join(m.gotLogs, "\n"); !strings.Contains(logs, "{\"id\":1}\n{\"id\":2}\n") {
+		t.Errorf("got no response body in dump %s", logs)
+	}
+}
@@ -0,0 +1,315 @@
+// Copyright (c) 2023, Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httpapitest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// jsonPath is a parsed JSONPath expression that supports the root identifier
+// $, dot and bracket notation child selectors, array indexes, including
+// negative ones, and the wildcard selector *.
+type jsonPath struct {
+	expression string
+	segments   []jsonPathSegment
+	definite   bool
+}
+
+type jsonPathSegment struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+func parseJSONPath(expression string) (*jsonPath, error) {
+	p := &jsonPath{
+		expression: expression,
+		definite:   true,
+	}
+	if !strings.HasPrefix(expression, "$") {
+		return nil, fmt.Errorf("json path %q: must start with $", expression)
+	}
+	s := expression[1:]
+	for s != "" {
+		var seg jsonPathSegment
+		switch {
+		case strings.HasPrefix(s, ".."):
+			return nil, fmt.Errorf("json path %q: recursive descent is not supported", expression)
+		case s[0] == '.':
+			s = s[1:]
+			end := strings.IndexAny(s, ".[")
+			if end < 0 {
+				end = len(s)
+			}
+			name := s[:end]
+			if name == "" {
+				return nil, fmt.Errorf("json path %q: empty member name", expression)
+			}
+			if name == "*" {
+				seg.wildcard = true
+			} else {
+				seg.key = name
+			}
+			s = s[end:]
+		case s[0] == '[' && len(s) > 1 && (s[1] == '\'' || s[1] == '"'):
+			end := strings.Index(s[2:], string(s[1])+"]")
+			if end < 0 {
+				return nil, fmt.Errorf("json path %q: unterminated bracket", expression)
+			}
+			seg.key = s[2 : 2+end]
+			s = s[2+end+2:]
+		case s[0] == '[':
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("json path %q: unterminated bracket", expression)
+			}
+			selector := s[1:end]
+			if selector == "*" {
+				seg.wildcard = true
+			} else {
+				i, err := strconv.Atoi(selector)
+				if err != nil {
+					return nil, fmt.Errorf("json path %q: invalid selector %q", expression, selector)
+				}
+				seg.index = i
+				seg.isIndex = true
+			}
+			s = s[end+1:]
+		default:
+			return nil, fmt.Errorf("json path %q: unexpected %q", expression, s)
+		}
+		if seg.wildcard {
+			p.definite = false
+		}
+		p.segments = append(p.segments, seg)
+	}
+	return p, nil
+}
+
+// find returns all values selected by the path.
+func (p *jsonPath) find(v interface{}) []interface{} {
+	values := []interface{}{v}
+	for _, seg := range p.segments {
+		var next []interface{}
+		for _, v := range values {
+			switch v := v.(type) {
+			case map[string]interface{}:
+				if seg.wildcard {
+					keys := make([]string, 0, len(v))
+					for k := range v {
+						keys = append(keys, k)
+					}
+					sort.Strings(keys)
+					for _, k := range keys {
+						next = append(next, v[k])
+					}
+				} else if !seg.isIndex {
+					if e, ok := v[seg.key]; ok {
+						next = append(next, e)
+					}
+				}
+			case []interface{}:
+				if seg.wildcard {
+					next = append(next, v...)
+				} else if seg.isIndex {
+					i := seg.index
+					if i < 0 {
+						i += len(v)
+					}
+					if i >= 0 && i < len(v) {
+						next = append(next, v[i])
+					}
+				}
+			}
+		}
+		values = next
+	}
+	return values
+}
+
+// value returns the single value selected by a definite path, or an array of
+// all selected values by a path with wildcards.
+func (p *jsonPath) value(v interface{}) (interface{}, bool) {
+	values := p.find(v)
+	if !p.definite {
+		if values == nil {
+			values = make([]interface{}, 0)
+		}
+		return values, true
+	}
+	if len(values) == 0 {
+		return nil, false
+	}
+	return values[0], true
+}
+
+type jsonPathExpectation struct {
+	path *jsonPath
+	want interface{}
+}
+
+// ExpectJSONPath validates that the value selected by the JSONPath expression
+// from the JSON response body of the request in the Request function is equal
+// to the JSON-encoded value provided here. Expressions support the root
+// identifier $, dot and bracket notation child selectors, array indexes and
+// the wildcard selector *. If the expression contains wildcards, the selected
+// values are compared as a JSON array.
+func ExpectJSONPath(expression string, value interface{}) Option {
+	return optionFunc(func(o *options) error {
+		p, err := parseJSONPath(expression)
+		if err != nil {
+			return err
+		}
+		want, err := normalizeJSON(value)
+		if err != nil {
+			return fmt.Errorf("json path %q: %w", expression, err)
+		}
+		o.jsonPaths = append(o.jsonPaths, jsonPathExpectation{path: p, want: want})
+		return nil
+	})
+}
+
+// ExpectJSONSubset validates that the JSON response body of the request in the
+// Request function contains the JSON-encoded value provided here. Objects in
+// the response may have additional members that are not in the expected value,
+// while arrays must have the same length with each element containing the
+// expected one.
+func ExpectJSONSubset(value interface{}) Option {
+	return optionFunc(func(o *options) error {
+		want, err := normalizeJSON(value)
+		if err != nil {
+			return fmt.Errorf("json subset: %w", err)
+		}
+		o.jsonSubsets = append(o.jsonSubsets, want)
+		return nil
+	})
+}
+
+// validateJSONExpectations validates the response body against JSON path and
+// subset expectations.
+func (o *options) validateJSONExpectations(body []byte) []AssertionError {
+	got, err := decodeJSON(body)
+	if err != nil {
+		return []AssertionError{{
+			Kind:    AssertionJSON,
+			Actual:  string(body),
+			Message: fmt.Sprintf("got invalid json response %q: %v", string(body), err),
+		}}
+	}
+	var failures []AssertionError
+	for _, e := range o.jsonPaths {
+		v, ok := e.path.value(got)
+		if !ok {
+			failures = append(failures, jsonMissingError(e.path.expression, e.want))
+			continue
+		}
+		if !jsonEqual(v, e.want) {
+			failures = append(failures, jsonValueError(e.path.expression, v, e.want))
+		}
+	}
+	for _, want := range o.jsonSubsets {
+		failures = append(failures, jsonSubsetErrors(got, want, "$")...)
+	}
+	return failures
+}
+
+func jsonSubsetErrors(got, want interface{}, path string) []AssertionError {
+	switch w := want.(type) {
+	case map[string]interface{}:
+		g, ok := got.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(w))
+		for k := range w {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var failures []AssertionError
+		for _, k := range keys {
+			p := jsonPathChild(path, k)
+			v, ok := g[k]
+			if !ok {
+				failures = append(failures, jsonMissingError(p, w[k]))
+				continue
+			}
+			failures = append(failures, jsonSubsetErrors(v, w[k], p)...)
+		}
+		return failures
+	case []interface{}:
+		g, ok := got.([]interface{})
+		if !ok {
+			break
+		}
+		if len(g) != len(w) {
+			return []AssertionError{{
+				Kind:     AssertionJSON,
+				Path:     path,
+				Expected: jsonString(w),
+				Actual:   jsonString(g),
+				Message:  fmt.Sprintf("got json array at %q with %v elements, want %v", path, len(g), len(w)),
+			}}
+		}
+		var failures []AssertionError
+		for i := range w {
+			failures = append(failures, jsonSubsetErrors(g[i], w[i], path+"["+strconv.Itoa(i)+"]")...)
+		}
+		return failures
+	}
+	if !jsonEqual(got, want) {
+		return []AssertionError{jsonValueError(path, got, want)}
+	}
+	return nil
+}
+
+func jsonValueError(path string, got, want interface{}) AssertionError {
+	return AssertionError{
+		Kind:     AssertionJSON,
+		Path:     path,
+		Expected: jsonString(want),
+		Actual:   jsonString(got),
+		Message:  fmt.Sprintf("got json value at %q %s, want %s", path, jsonString(got), jsonString(want)),
+	}
+}
+
+func jsonMissingError(path string, want interface{}) AssertionError {
+	return AssertionError{
+		Kind:     AssertionJSON,
+		Path:     path,
+		Expected: jsonString(want),
+		Message:  fmt.Sprintf("got no json value at %q, want %s", path, jsonString(want)),
+	}
+}
+
+func jsonPathChild(path, key string) string {
+	if key != "" && strings.Trim(key, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_") == "" {
+		return path + "." + key
+	}
+	return path + "['" + key + "']"
+}
+
+// normalizeJSON encodes the value and decodes it to the generic JSON value
+// representation with json.Number numbers.
+func normalizeJSON(v interface{}) (interface{}, error) {
+	if raw, ok := v.(json.RawMessage); ok {
+		return decodeJSON(raw)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	n, err := decodeJSON(b)
+	if err != nil {
+		return nil, errors.New("invalid json")
+	}
+	return n, nil
+}
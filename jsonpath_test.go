@@ -0,0 +1,118 @@
+// Copyright (c) 2023, Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httpapitest_test
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"resenje.org/httpapitest"
+)
+
+func TestExpectJSONPath(t *testing.T) {
+
+	c, endpoint := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":1,"name":"test","roles":["admin","user"],"items":[{"id":1},{"id":2}],"a.b":{"c":true}}`)
+	}))
+
+	for _, tc := range []struct {
+		path       string
+		value      interface{}
+		wantErrors []string
+		wantFatal  string
+	}{
+		{path: "$.id", value: 1},
+		{path: "$.id", value: 1.0},
+		{path: "$['name']", value: "test"},
+		{path: "$.roles[0]", value: "admin"},
+		{path: "$.roles[-1]", value: "user"},
+		{path: "$.items[*].id", value: []int{1, 2}},
+		{path: "$.items.*.missing", value: []int{}},
+		{path: `$["a.b"].c`, value: true},
+		{path: "$", value: map[string]interface{}{"id": 1, "name": "test", "roles": []string{"admin", "user"}, "items": []interface{}{map[string]int{"id": 1}, map[string]int{"id": 2}}, "a.b": map[string]bool{"c": true}}},
+		{
+			path:       "$.name",
+			value:      "other",
+			wantErrors: []string{`got json value at "$.name" "test", want "other"`},
+		},
+		{
+			path:       "$.roles[2]",
+			value:      "guest",
+			wantErrors: []string{`got no json value at "$.roles[2]", want "guest"`},
+		},
+		{
+			path:      "$..id",
+			value:     1,
+			wantFatal: `json path "$..id": recursive descent is not supported`,
+		},
+		{
+			path:      "id",
+			value:     1,
+			wantFatal: `json path "id": must start with $`,
+		},
+	} {
+		t.Run(tc.path, func(t *testing.T) {
+			var m *mock
+			assert(t, lastError(tc.wantErrors), tc.wantFatal, func(mm *mock) {
+				m = mm
+				httpapitest.Request(m, c, http.MethodGet, endpoint,
+					httpapitest.ExpectJSONPath(tc.path, tc.value),
+				)
+			})
+			if !reflect.DeepEqual(m.gotErrors, tc.wantErrors) {
+				t.Errorf("got errors %q, want %q", m.gotErrors, tc.wantErrors)
+			}
+		})
+	}
+}
+
+func TestExpectJSONSubset(t *testing.T) {
+
+	c, endpoint := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":1,"name":"test","roles":["admin","user"],"meta":{"created":"today","weird key":null}}`)
+	}))
+
+	assert(t, "", "", func(m *mock) {
+		httpapitest.Request(m, c, http.MethodGet, endpoint,
+			httpapitest.ExpectJSONSubset(map[string]interface{}{
+				"id":    1,
+				"roles": []string{"admin", "user"},
+				"meta":  map[string]string{"created": "today"},
+			}),
+		)
+	})
+
+	var m *mock
+	assert(t, `got json array at "$.roles" with 2 elements, want 1`, "", func(mm *mock) {
+		m = mm
+		httpapitest.Request(m, c, http.MethodGet, endpoint,
+			httpapitest.ExpectJSONSubset(map[string]interface{}{
+				"id":    2,
+				"email": "test@example.com",
+				"meta":  map[string]interface{}{"weird key": "value"},
+				"roles": []string{"admin"},
+			}),
+		)
+	})
+	wantErrors := []string{
+		`got no json value at "$.email", want "test@example.com"`,
+		`got json value at "$.id" 1, want 2`,
+		`got json value at "$.meta['weird key']" null, want "value"`,
+		`got json array at "$.roles" with 2 elements, want 1`,
+	}
+	if !reflect.DeepEqual(m.gotErrors, wantErrors) {
+		t.Errorf("got errors %q, want %q", m.gotErrors, wantErrors)
+	}
+}
+
+func lastError(errors []string) string {
+	if len(errors) == 0 {
+		return ""
+	}
+	return errors[len(errors)-1]
+}
@@ -0,0 +1,903 @@
+// Copyright (c) 2023, Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httpapitest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// defaultSchemaBaseURI is used to resolve references in JSON schemas that do
+// not declare their own absolute $id.
+const defaultSchemaBaseURI = "https://httpapitest.invalid/schema.json"
+
+// maxSchemaDepth limits the number of nested schema evaluations to detect
+// infinite $ref recursion.
+const maxSchemaDepth = 256
+
+// jsonSchema is a compiled JSON Schema draft 2020-12 document. It supports
+// all assertion and applicator keywords, with exception of format and content
+// vocabularies which are treated as annotations.
+type jsonSchema struct {
+	root      interface{}
+	base      string
+	resources map[string]schemaNode
+	// dynamicAnchors holds schemas with the $dynamicAnchor keyword by the
+	// resource URI and the anchor name, as they are resolved in the dynamic
+	// scope by the $dynamicRef keyword.
+	dynamicAnchors map[string]schemaNode
+	patterns       map[string]*regexp.Regexp
+	// openAPI30 enables the OpenAPI 3.0 schema object dialect with the
+	// nullable keyword and boolean exclusiveMinimum and exclusiveMaximum
+	// keywords.
+	openAPI30 bool
+}
+
+// schemaNode is a schema with the base URI that is in effect for it, not
+// taking into account its own $id keyword.
+type schemaNode struct {
+	schema interface{}
+	base   string
+}
+
+// schemaViolation describes a single JSON schema validation failure.
+type schemaViolation struct {
+	instanceLocation string
+	keywordLocation  string
+	message          string
+}
+
+func (v schemaViolation) Error() string {
+	return fmt.Sprintf("json schema violation at %q (%s): %s", v.instanceLocation, v.keywordLocation, v.message)
+}
+
+// schemaEvaluation holds property names and array items that were evaluated
+// by successfully validated subschemas, as required by unevaluatedProperties
+// and unevaluatedItems keywords.
+type schemaEvaluation struct {
+	props    map[string]struct{}
+	items    int
+	allItems bool
+	// containsItems are positions of array items matched by the contains
+	// keyword.
+	containsItems map[int]struct{}
+}
+
+func (e *schemaEvaluation) addProp(name string) {
+	if e.props == nil {
+		e.props = make(map[string]struct{})
+	}
+	e.props[name] = struct{}{}
+}
+
+func (e *schemaEvaluation) addContainsItem(i int) {
+	if e.containsItems == nil {
+		e.containsItems = make(map[int]struct{})
+	}
+	e.containsItems[i] = struct{}{}
+}
+
+func (e *schemaEvaluation) merge(o schemaEvaluation) {
+	for name := range o.props {
+		e.addProp(name)
+	}
+	for i := range o.containsItems {
+		e.addContainsItem(i)
+	}
+	if o.items > e.items {
+		e.items = o.items
+	}
+	if o.allItems {
+		e.allItems = true
+	}
+}
+
+func compileJSONSchema(data []byte) (*jsonSchema, error) {
+	root, err := decodeJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("json schema: %w", err)
+	}
+	s := &jsonSchema{
+		root:           root,
+		base:           defaultSchemaBaseURI,
+		resources:      make(map[string]schemaNode),
+		dynamicAnchors: make(map[string]schemaNode),
+		patterns:       make(map[string]*regexp.Regexp),
+	}
+	s.resources[defaultSchemaBaseURI] = schemaNode{schema: root, base: defaultSchemaBaseURI}
+	if err := s.index(root, defaultSchemaBaseURI, ""); err != nil {
+		return nil, fmt.Errorf("json schema: %w", err)
+	}
+	return s, nil
+}
+
+// index walks the schema, validates keyword types that are required for the
+// evaluation, registers identified resources and anchors, and compiles regular
+// expressions.
+func (s *jsonSchema) index(schema interface{}, base, location string) error {
+	switch schema.(type) {
+	case bool:
+		return nil
+	case map[string]interface{}:
+	default:
+		return fmt.Errorf("%s: got %s, want object or boolean schema", schemaLocation(location), jsonType(schema))
+	}
+	m := schema.(map[string]interface{})
+	parentBase := base
+	if v, ok := m["$id"]; ok {
+		id, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("%s/$id: got %s, want string", schemaLocation(location), jsonType(v))
+		}
+		base = resolveURI(base, id)
+		s.resources[base] = schemaNode{schema: schema, base: parentBase}
+	}
+	for _, keyword := range []string{"$anchor", "$dynamicAnchor"} {
+		if v, ok := m[keyword]; ok {
+			anchor, ok := v.(string)
+			if !ok {
+				return fmt.Errorf("%s/%s: got %s, want string", schemaLocation(location), keyword, jsonType(v))
+			}
+			s.resources[base+"#"+anchor] = schemaNode{schema: schema, base: parentBase}
+			if keyword == "$dynamicAnchor" {
+				s.dynamicAnchors[base+"#"+anchor] = schemaNode{schema: schema, base: parentBase}
+			}
+		}
+	}
+	for _, keyword := range []string{"$ref", "$dynamicRef"} {
+		if v, ok := m[keyword]; ok {
+			if _, ok := v.(string); !ok {
+				return fmt.Errorf("%s/%s: got %s, want string", schemaLocation(location), keyword, jsonType(v))
+			}
+		}
+	}
+	if v, ok := m["pattern"]; ok {
+		p, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("%s/pattern: got %s, want string", schemaLocation(location), jsonType(v))
+		}
+		if err := s.compilePattern(p); err != nil {
+			return fmt.Errorf("%s/pattern: %w", schemaLocation(location), err)
+		}
+	}
+	if v, ok := m["patternProperties"].(map[string]interface{}); ok {
+		for p := range v {
+			if err := s.compilePattern(p); err != nil {
+				return fmt.Errorf("%s/patternProperties: %w", schemaLocation(location), err)
+			}
+		}
+	}
+	for _, keyword := range []string{"$defs", "definitions", "properties", "patternProperties", "dependentSchemas"} {
+		v, ok := m[keyword]
+		if !ok {
+			continue
+		}
+		subschemas, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s/%s: got %s, want object", schemaLocation(location), keyword, jsonType(v))
+		}
+		for name, sub := range subschemas {
+			if err := s.index(sub, base, location+"/"+keyword+"/"+escapeJSONPointer(name)); err != nil {
+				return err
+			}
+		}
+	}
+	for _, keyword := range []string{"allOf", "anyOf", "oneOf", "prefixItems"} {
+		v, ok := m[keyword]
+		if !ok {
+			continue
+		}
+		subschemas, ok := v.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s/%s: got %s, want array", schemaLocation(location), keyword, jsonType(v))
+		}
+		for i, sub := range subschemas {
+			if err := s.index(sub, base, location+"/"+keyword+"/"+strconv.Itoa(i)); err != nil {
+				return err
+			}
+		}
+	}
+	for _, keyword := range []string{"items", "additionalProperties", "contains", "not", "if", "then", "else", "propertyNames", "unevaluatedItems", "unevaluatedProperties"} {
+		if sub, ok := m[keyword]; ok {
+			if err := s.index(sub, base, location+"/"+keyword); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *jsonSchema) compilePattern(p string) error {
+	if _, ok := s.patterns[p]; ok {
+		return nil
+	}
+	re, err := regexp.Compile(p)
+	if err != nil {
+		return err
+	}
+	s.patterns[p] = re
+	return nil
+}
+
+// resolveDynamic returns the schema referenced by the $dynamicRef keyword. If
+// the statically resolved schema n has a $dynamicAnchor with the name in the
+// reference fragment, the outermost schema resource in the dynamic scope with
+// the same dynamic anchor is used instead.
+func (s *jsonSchema) resolveDynamic(n schemaNode, ref string, scope []string) schemaNode {
+	i := strings.LastIndexByte(ref, '#')
+	if i < 0 {
+		return n
+	}
+	anchor := ref[i+1:]
+	if anchor == "" || strings.HasPrefix(anchor, "/") {
+		return n
+	}
+	m, ok := n.schema.(map[string]interface{})
+	if !ok || m["$dynamicAnchor"] != anchor {
+		return n
+	}
+	for _, uri := range scope {
+		if d, ok := s.dynamicAnchors[uri+"#"+anchor]; ok {
+			return d
+		}
+	}
+	return n
+}
+
+// resolve returns the schema referenced by the ref relative to the base URI.
+func (s *jsonSchema) resolve(base, ref string) (schemaNode, error) {
+	u, err := url.Parse(resolveURI(base, ref))
+	if err != nil {
+		return schemaNode{}, fmt.Errorf("invalid reference %q: %w", ref, err)
+	}
+	fragment := u.Fragment
+	u.Fragment = ""
+	u.RawFragment = ""
+	uri := u.String()
+
+	if fragment != "" && !strings.HasPrefix(fragment, "/") {
+		n, ok := s.resources[uri+"#"+fragment]
+		if !ok {
+			return schemaNode{}, fmt.Errorf("unknown anchor in reference %q", ref)
+		}
+		return n, nil
+	}
+
+	n, ok := s.resources[uri]
+	if !ok {
+		return schemaNode{}, fmt.Errorf("unknown schema in reference %q", ref)
+	}
+	if fragment == "" {
+		return n, nil
+	}
+	for _, token := range strings.Split(fragment[1:], "/") {
+		token = unescapeJSONPointer(token)
+		if m, ok := n.schema.(map[string]interface{}); ok {
+			if id, ok := m["$id"].(string); ok {
+				n.base = resolveURI(n.base, id)
+			}
+		}
+		switch v := n.schema.(type) {
+		case map[string]interface{}:
+			child, ok := v[token]
+			if !ok {
+				return schemaNode{}, fmt.Errorf("unresolvable reference %q", ref)
+			}
+			n.schema = child
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(v) {
+				return schemaNode{}, fmt.Errorf("unresolvable reference %q", ref)
+			}
+			n.schema = v[i]
+		default:
+			return schemaNode{}, fmt.Errorf("unresolvable reference %q", ref)
+		}
+	}
+	return n, nil
+}
+
+// validateJSON decodes JSON data and validates it against the schema.
+func (s *jsonSchema) validateJSON(data []byte) ([]schemaViolation, error) {
+	instance, err := decodeJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	return s.validateValue(instance), nil
+}
+
+// validateValue validates JSON value decoded with json.Number numbers against
+// the schema.
+func (s *jsonSchema) validateValue(instance interface{}) []schemaViolation {
+	violations, _ := s.validate(s.root, s.base, instance, "", "", 0, nil)
+	return violations
+}
+
+// validateAt validates JSON value against a subschema of the document that is
+// at the keyword location.
+func (s *jsonSchema) validateAt(schema, instance interface{}, keywordLocation string) []schemaViolation {
+	violations, _ := s.validate(schema, s.base, instance, "", keywordLocation, 0, nil)
+	return violations
+}
+
+// validate validates the instance against the schema. The scope holds URIs of
+// schema resources that were entered before the schema, as required by the
+// $dynamicRef keyword.
+func (s *jsonSchema) validate(schema interface{}, base string, instance interface{}, instanceLocation, keywordLocation string, depth int, scope []string) (violations []schemaViolation, evaluation schemaEvaluation) {
+	fail := func(keyword, format string, a ...interface{}) {
+		violations = append(violations, schemaViolation{
+			instanceLocation: instanceLocation,
+			keywordLocation:  schemaLocation(keywordLocation + "/" + keyword),
+			message:          fmt.Sprintf(format, a...),
+		})
+	}
+
+	m, ok := schema.(map[string]interface{})
+	if !ok {
+		if b, ok := schema.(bool); ok && !b {
+			violations = append(violations, schemaViolation{
+				instanceLocation: instanceLocation,
+				keywordLocation:  schemaLocation(keywordLocation),
+				message:          "no value is allowed",
+			})
+		}
+		return violations, evaluation
+	}
+	if depth > maxSchemaDepth {
+		fail("$ref", "maximum schema depth %v exceeded", maxSchemaDepth)
+		return violations, evaluation
+	}
+	if id, ok := m["$id"].(string); ok {
+		base = resolveURI(base, id)
+	}
+	if len(scope) == 0 || scope[len(scope)-1] != base {
+		scope = append(scope[:len(scope):len(scope)], base)
+	}
+
+	// apply validates instance against a subschema at the same instance
+	// location and returns true if it is valid.
+	apply := func(sub interface{}, keyword string, collect bool) (bool, schemaEvaluation) {
+		vs, e := s.validate(sub, base, instance, instanceLocation, keywordLocation+"/"+keyword, depth+1, scope)
+		if collect {
+			violations = append(violations, vs...)
+		}
+		return len(vs) == 0, e
+	}
+
+	for _, keyword := range []string{"$ref", "$dynamicRef"} {
+		ref, ok := m[keyword].(string)
+		if !ok {
+			continue
+		}
+		n, err := s.resolve(base, ref)
+		if err != nil {
+			fail(keyword, "%v", err)
+			continue
+		}
+		if keyword == "$dynamicRef" {
+			n = s.resolveDynamic(n, ref, scope)
+		}
+		vs, e := s.validate(n.schema, n.base, instance, instanceLocation, keywordLocation+"/"+keyword, depth+1, scope)
+		violations = append(violations, vs...)
+		if len(vs) == 0 {
+			evaluation.merge(e)
+		}
+	}
+
+	if v, ok := m["type"]; ok {
+		var types []string
+		switch v := v.(type) {
+		case string:
+			types = []string{v}
+		case []interface{}:
+			for _, t := range v {
+				if t, ok := t.(string); ok {
+					types = append(types, t)
+				}
+			}
+		}
+		if nullable, _ := m["nullable"].(bool); s.openAPI30 && nullable {
+			types = append(types, "null")
+		}
+		if !matchesJSONType(instance, types) {
+			fail("type", "got %s, want %s", jsonType(instance), strings.Join(types, " or "))
+		}
+	}
+
+	if v, ok := m["enum"].([]interface{}); ok {
+		var found bool
+		for _, e := range v {
+			if jsonEqual(instance, e) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("enum", "got %s, want one of %s", jsonString(instance), jsonString(v))
+		}
+	}
+
+	if v, ok := m["const"]; ok {
+		if !jsonEqual(instance, v) {
+			fail("const", "got %s, want %s", jsonString(instance), jsonString(v))
+		}
+	}
+
+	for _, keyword := range []string{"allOf", "anyOf", "oneOf"} {
+		subschemas, ok := m[keyword].([]interface{})
+		if !ok {
+			continue
+		}
+		var valid int
+		for i, sub := range subschemas {
+			ok, e := apply(sub, keyword+"/"+strconv.Itoa(i), keyword == "allOf")
+			if ok {
+				valid++
+				evaluation.merge(e)
+			}
+		}
+		switch {
+		case keyword == "anyOf" && valid == 0:
+			fail(keyword, "value does not match any schema")
+		case keyword == "oneOf" && valid != 1:
+			fail(keyword, "value matches %v schemas, want exactly one", valid)
+		}
+	}
+
+	if sub, ok := m["not"]; ok {
+		if ok, _ := apply(sub, "not", false); ok {
+			fail("not", "value must not match the schema")
+		}
+	}
+
+	if sub, ok := m["if"]; ok {
+		if ok, e := apply(sub, "if", false); ok {
+			evaluation.merge(e)
+			if sub, ok := m["then"]; ok {
+				if ok, e := apply(sub, "then", true); ok {
+					evaluation.merge(e)
+				}
+			}
+		} else if sub, ok := m["else"]; ok {
+			if ok, e := apply(sub, "else", true); ok {
+				evaluation.merge(e)
+			}
+		}
+	}
+
+	switch instance := instance.(type) {
+	case json.Number:
+		s.validateNumber(m, instance, fail)
+	case string:
+		s.validateString(m, instance, fail)
+	case []interface{}:
+		vs, e := s.validateArray(m, base, instance, instanceLocation, keywordLocation, depth, scope, evaluation, fail)
+		violations = append(violations, vs...)
+		evaluation.merge(e)
+	case map[string]interface{}:
+		vs, e := s.validateObject(m, base, instance, instanceLocation, keywordLocation, depth, scope, evaluation, fail)
+		violations = append(violations, vs...)
+		evaluation.merge(e)
+	}
+
+	return violations, evaluation
+}
+
+func (s *jsonSchema) validateNumber(m map[string]interface{}, instance json.Number, fail func(keyword, format string, a ...interface{})) {
+	n, ok := jsonRat(instance)
+	if !ok {
+		return
+	}
+	if v, ok := jsonRat(m["multipleOf"]); ok && v.Sign() > 0 {
+		if !new(big.Rat).Quo(n, v).IsInt() {
+			fail("multipleOf", "got %s, want multiple of %s", instance, m["multipleOf"])
+		}
+	}
+	// in OpenAPI 3.0, boolean exclusiveMaximum and exclusiveMinimum make
+	// maximum and minimum exclusive
+	exclusiveMaximum, _ := m["exclusiveMaximum"].(bool)
+	exclusiveMinimum, _ := m["exclusiveMinimum"].(bool)
+	if v, ok := jsonRat(m["maximum"]); ok {
+		if s.openAPI30 && exclusiveMaximum {
+			if n.Cmp(v) >= 0 {
+				fail("exclusiveMaximum", "got %s, want less than %s", instance, m["maximum"])
+			}
+		} else if n.Cmp(v) > 0 {
+			fail("maximum", "got %s, want at most %s", instance, m["maximum"])
+		}
+	}
+	if v, ok := jsonRat(m["exclusiveMaximum"]); ok && n.Cmp(v) >= 0 {
+		fail("exclusiveMaximum", "got %s, want less than %s", instance, m["exclusiveMaximum"])
+	}
+	if v, ok := jsonRat(m["minimum"]); ok {
+		if s.openAPI30 && exclusiveMinimum {
+			if n.Cmp(v) <= 0 {
+				fail("exclusiveMinimum", "got %s, want greater than %s", instance, m["minimum"])
+			}
+		} else if n.Cmp(v) < 0 {
+			fail("minimum", "got %s, want at least %s", instance, m["minimum"])
+		}
+	}
+	if v, ok := jsonRat(m["exclusiveMinimum"]); ok && n.Cmp(v) <= 0 {
+		fail("exclusiveMinimum", "got %s, want greater than %s", instance, m["exclusiveMinimum"])
+	}
+}
+
+func (s *jsonSchema) validateString(m map[string]interface{}, instance string, fail func(keyword, format string, a ...interface{})) {
+	length := utf8.RuneCountInString(instance)
+	if v, ok := jsonInt(m["maxLength"]); ok && length > v {
+		fail("maxLength", "got string length %v, want at most %v", length, v)
+	}
+	if v, ok := jsonInt(m["minLength"]); ok && length < v {
+		fail("minLength", "got string length %v, want at least %v", length, v)
+	}
+	if p, ok := m["pattern"].(string); ok {
+		if re := s.patterns[p]; re != nil && !re.MatchString(instance) {
+			fail("pattern", "got %q, want match of pattern %q", instance, p)
+		}
+	}
+}
+
+func (s *jsonSchema) validateArray(m map[string]interface{}, base string, instance []interface{}, instanceLocation, keywordLocation string, depth int, scope []string, evaluated schemaEvaluation, fail func(keyword, format string, a ...interface{})) (violations []schemaViolation, evaluation schemaEvaluation) {
+	item := func(i int, sub interface{}, keyword string) bool {
+		vs, _ := s.validate(sub, base, instance[i], instanceLocation+"/"+strconv.Itoa(i), keywordLocation+"/"+keyword, depth+1, scope)
+		violations = append(violations, vs...)
+		return len(vs) == 0
+	}
+
+	if v, ok := jsonInt(m["maxItems"]); ok && len(instance) > v {
+		fail("maxItems", "got %v items, want at most %v", len(instance), v)
+	}
+	if v, ok := jsonInt(m["minItems"]); ok && len(instance) < v {
+		fail("minItems", "got %v items, want at least %v", len(instance), v)
+	}
+	if v, ok := m["uniqueItems"].(bool); ok && v {
+	unique:
+		for i := range instance {
+			for j := i + 1; j < len(instance); j++ {
+				if jsonEqual(instance[i], instance[j]) {
+					fail("uniqueItems", "items at positions %v and %v are equal", i, j)
+					break unique
+				}
+			}
+		}
+	}
+
+	var prefix int
+	if subschemas, ok := m["prefixItems"].([]interface{}); ok {
+		for i, sub := range subschemas {
+			if i >= len(instance) {
+				break
+			}
+			item(i, sub, "prefixItems/"+strconv.Itoa(i))
+			prefix = i + 1
+		}
+		evaluation.items = prefix
+	}
+	if sub, ok := m["items"]; ok {
+		for i := prefix; i < len(instance); i++ {
+			item(i, sub, "items")
+		}
+		evaluation.allItems = true
+	}
+
+	if sub, ok := m["contains"]; ok {
+		var contains int
+		for i := range instance {
+			vs, _ := s.validate(sub, base, instance[i], instanceLocation+"/"+strconv.Itoa(i), keywordLocation+"/contains", depth+1, scope)
+			if len(vs) == 0 {
+				contains++
+				evaluation.addContainsItem(i)
+			}
+		}
+		min := 1
+		if v, ok := jsonInt(m["minContains"]); ok {
+			min = v
+		}
+		if contains < min {
+			fail("contains", "got %v matching items, want at least %v", contains, min)
+		}
+		if v, ok := jsonInt(m["maxContains"]); ok && contains > v {
+			fail("maxContains", "got %v matching items, want at most %v", contains, v)
+		}
+	}
+
+	if sub, ok := m["unevaluatedItems"]; ok {
+		evaluated.merge(evaluation)
+		if !evaluated.allItems {
+			for i := evaluated.items; i < len(instance); i++ {
+				if _, ok := evaluated.containsItems[i]; ok {
+					continue
+				}
+				if b, ok := sub.(bool); ok && !b {
+					fail("unevaluatedItems", "unevaluated item at position %v is not allowed", i)
+					continue
+				}
+				item(i, sub, "unevaluatedItems")
+			}
+		}
+		evaluation.allItems = true
+	}
+
+	return violations, evaluation
+}
+
+func (s *jsonSchema) validateObject(m map[string]interface{}, base string, instance map[string]interface{}, instanceLocation, keywordLocation string, depth int, scope []string, evaluated schemaEvaluation, fail func(keyword, format string, a ...interface{})) (violations []schemaViolation, evaluation schemaEvaluation) {
+	property := func(name string, sub interface{}, keyword string) {
+		vs, _ := s.validate(sub, base, instance[name], instanceLocation+"/"+escapeJSONPointer(name), keywordLocation+"/"+keyword, depth+1, scope)
+		violations = append(violations, vs...)
+	}
+	names := make([]string, 0, len(instance))
+	for name := range instance {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if v, ok := jsonInt(m["maxProperties"]); ok && len(instance) > v {
+		fail("maxProperties", "got %v properties, want at most %v", len(instance), v)
+	}
+	if v, ok := jsonInt(m["minProperties"]); ok && len(instance) < v {
+		fail("minProperties", "got %v properties, want at least %v", len(instance), v)
+	}
+	if v, ok := m["required"].([]interface{}); ok {
+		for _, name := range v {
+			if name, ok := name.(string); ok {
+				if _, ok := instance[name]; !ok {
+					fail("required", "missing required property %q", name)
+				}
+			}
+		}
+	}
+	if v, ok := m["dependentRequired"].(map[string]interface{}); ok {
+		for _, name := range names {
+			required, ok := v[name].([]interface{})
+			if !ok {
+				continue
+			}
+			for _, r := range required {
+				if r, ok := r.(string); ok {
+					if _, ok := instance[r]; !ok {
+						fail("dependentRequired/"+escapeJSONPointer(name), "missing property %q required by property %q", r, name)
+					}
+				}
+			}
+		}
+	}
+	if v, ok := m["dependentSchemas"].(map[string]interface{}); ok {
+		for _, name := range names {
+			sub, ok := v[name]
+			if !ok {
+				continue
+			}
+			vs, e := s.validate(sub, base, instance, instanceLocation, keywordLocation+"/dependentSchemas/"+escapeJSONPointer(name), depth+1, scope)
+			violations = append(violations, vs...)
+			if len(vs) == 0 {
+				evaluation.merge(e)
+			}
+		}
+	}
+	if sub, ok := m["propertyNames"]; ok {
+		for _, name := range names {
+			vs, _ := s.validate(sub, base, name, instanceLocation+"/"+escapeJSONPointer(name), keywordLocation+"/propertyNames", depth+1, scope)
+			violations = append(violations, vs...)
+		}
+	}
+
+	matched := make(map[string]struct{})
+	if v, ok := m["properties"].(map[string]interface{}); ok {
+		for _, name := range names {
+			sub, ok := v[name]
+			if !ok {
+				continue
+			}
+			property(name, sub, "properties/"+escapeJSONPointer(name))
+			matched[name] = struct{}{}
+			evaluation.addProp(name)
+		}
+	}
+	if v, ok := m["patternProperties"].(map[string]interface{}); ok {
+		patterns := make([]string, 0, len(v))
+		for p := range v {
+			patterns = append(patterns, p)
+		}
+		sort.Strings(patterns)
+		for _, name := range names {
+			for _, p := range patterns {
+				if re := s.patterns[p]; re != nil && re.MatchString(name) {
+					property(name, v[p], "patternProperties/"+escapeJSONPointer(p))
+					matched[name] = struct{}{}
+					evaluation.addProp(name)
+				}
+			}
+		}
+	}
+	if sub, ok := m["additionalProperties"]; ok {
+		for _, name := range names {
+			if _, ok := matched[name]; ok {
+				continue
+			}
+			if b, ok := sub.(bool); ok && !b {
+				fail("additionalProperties", "additional property %q is not allowed", name)
+			} else {
+				property(name, sub, "additionalProperties")
+			}
+			evaluation.addProp(name)
+		}
+	}
+	if sub, ok := m["unevaluatedProperties"]; ok {
+		evaluated.merge(evaluation)
+		for _, name := range names {
+			if _, ok := evaluated.props[name]; ok {
+				continue
+			}
+			if b, ok := sub.(bool); ok && !b {
+				fail("unevaluatedProperties", "unevaluated property %q is not allowed", name)
+			} else {
+				property(name, sub, "unevaluatedProperties")
+			}
+			evaluation.addProp(name)
+		}
+	}
+
+	return violations, evaluation
+}
+
+// decodeJSON decodes a single JSON value preserving numbers as json.Number.
+func decodeJSON(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return nil, errors.New("unexpected data after json value")
+	}
+	return v, nil
+}
+
+func jsonType(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		if r, ok := jsonRat(v); ok && r.IsInt() {
+			return "integer"
+		}
+		return "number"
+	case float64:
+		if v == float64(int64(v)) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func matchesJSONType(v interface{}, types []string) bool {
+	got := jsonType(v)
+	for _, t := range types {
+		if t == got || (t == "number" && got == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonEqual compares decoded JSON values where numbers are equal if they
+// represent the same mathematical value.
+func jsonEqual(a, b interface{}) bool {
+	switch a := a.(type) {
+	case json.Number, float64:
+		ra, ok := jsonRat(a)
+		if !ok {
+			return false
+		}
+		rb, ok := jsonRat(b)
+		return ok && ra.Cmp(rb) == 0
+	case []interface{}:
+		b, ok := b.([]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !jsonEqual(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		b, ok := b.(map[string]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k, v := range a {
+			w, ok := b[k]
+			if !ok || !jsonEqual(v, w) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
+func jsonRat(v interface{}) (*big.Rat, bool) {
+	switch v := v.(type) {
+	case json.Number:
+		return new(big.Rat).SetString(string(v))
+	case float64:
+		r := new(big.Rat)
+		if r.SetFloat64(v) == nil {
+			return nil, false
+		}
+		return r, true
+	}
+	return nil, false
+}
+
+func jsonInt(v interface{}) (int, bool) {
+	r, ok := jsonRat(v)
+	if !ok || !r.IsInt() || !r.Num().IsInt64() {
+		return 0, false
+	}
+	return int(r.Num().Int64()), true
+}
+
+func jsonString(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+func resolveURI(base, ref string) string {
+	b, err := url.Parse(base)
+	if err != nil {
+		return ref
+	}
+	r, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return b.ResolveReference(r).String()
+}
+
+func schemaLocation(pointer string) string {
+	return "#" + pointer
+}
+
+var (
+	jsonPointerEscaper   = strings.NewReplacer("~", "~0", "/", "~1")
+	jsonPointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+)
+
+func escapeJSONPointer(s string) string {
+	return jsonPointerEscaper.Replace(s)
+}
+
+func unescapeJSONPointer(s string) string {
+	return jsonPointerUnescaper.Replace(s)
+}
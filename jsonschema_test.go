@@ -0,0 +1,195 @@
+// Copyright (c) 2023, Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httpapitest_test
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"resenje.org/httpapitest"
+)
+
+func TestExpectJSONSchema(t *testing.T) {
+
+	for _, tc := range []struct {
+		name       string
+		schema     string
+		response   string
+		wantErrors []string
+	}{
+		{
+			name:     "valid",
+			schema:   `{"type":"object","properties":{"id":{"type":"integer"},"name":{"type":"string"}},"required":["id"]}`,
+			response: `{"id":1,"name":"test"}`,
+		},
+		{
+			name:     "multiple violations",
+			schema:   `{"type":"object","properties":{"id":{"type":"integer"},"tags":{"type":"array","items":{"type":"string","maxLength":3}}},"required":["id","name"],"additionalProperties":false}`,
+			response: `{"id":1.5,"tags":["a","long"],"extra":true}`,
+			wantErrors: []string{
+				`json schema violation at "" (#/required): missing required property "name"`,
+				`json schema violation at "" (#/additionalProperties): additional property "extra" is not allowed`,
+				`json schema violation at "/id" (#/properties/id/type): got number, want integer`,
+				`json schema violation at "/tags/1" (#/properties/tags/items/maxLength): got string length 4, want at most 3`,
+			},
+		},
+		{
+			name:     "ref and defs",
+			schema:   `{"$defs":{"positive":{"type":"integer","exclusiveMinimum":0}},"type":"array","items":{"$ref":"#/$defs/positive"}}`,
+			response: `[1,2,0]`,
+			wantErrors: []string{
+				`json schema violation at "/2" (#/items/$ref/exclusiveMinimum): got 0, want greater than 0`,
+			},
+		},
+		{
+			name:     "nullable is not a keyword",
+			schema:   `{"type":"string","nullable":true}`,
+			response: `null`,
+			wantErrors: []string{
+				`json schema violation at "" (#/type): got null, want string`,
+			},
+		},
+		{
+			name:     "boolean exclusive minimum is not a keyword",
+			schema:   `{"minimum":0,"exclusiveMinimum":true}`,
+			response: `0`,
+		},
+		{
+			name:     "anchor",
+			schema:   `{"$id":"https://example.com/item.json","$defs":{"name":{"$anchor":"name","type":"string","pattern":"^[a-z]+$"}},"properties":{"name":{"$ref":"#name"}}}`,
+			response: `{"name":"Test"}`,
+			wantErrors: []string{
+				`json schema violation at "/name" (#/properties/name/$ref/pattern): got "Test", want match of pattern "^[a-z]+$"`,
+			},
+		},
+		{
+			name:     "combinators",
+			schema:   `{"properties":{"a":{"anyOf":[{"type":"string"},{"type":"null"}]},"b":{"oneOf":[{"type":"integer"},{"minimum":0}]},"c":{"not":{"const":"x"}},"d":{"enum":[1,"two"]}}}`,
+			response: `{"a":1,"b":3,"c":"x","d":2.0}`,
+			wantErrors: []string{
+				`json schema violation at "/a" (#/properties/a/anyOf): value does not match any schema`,
+				`json schema violation at "/b" (#/properties/b/oneOf): value matches 2 schemas, want exactly one`,
+				`json schema violation at "/c" (#/properties/c/not): value must not match the schema`,
+				`json schema violation at "/d" (#/properties/d/enum): got 2.0, want one of [1,"two"]`,
+			},
+		},
+		{
+			name:     "conditional",
+			schema:   `{"if":{"properties":{"kind":{"const":"user"}}},"then":{"required":["email"]},"else":{"required":["url"]}}`,
+			response: `{"kind":"user"}`,
+			wantErrors: []string{
+				`json schema violation at "" (#/then/required): missing required property "email"`,
+			},
+		},
+		{
+			name:     "unevaluated properties",
+			schema:   `{"allOf":[{"properties":{"a":true}}],"properties":{"b":true},"unevaluatedProperties":false}`,
+			response: `{"a":1,"b":2,"c":3}`,
+			wantErrors: []string{
+				`json schema violation at "" (#/unevaluatedProperties): unevaluated property "c" is not allowed`,
+			},
+		},
+		{
+			name:     "arrays",
+			schema:   `{"prefixItems":[{"type":"string"}],"items":{"type":"integer"},"contains":{"const":5},"uniqueItems":true,"minItems":2}`,
+			response: `["a",1,1]`,
+			wantErrors: []string{
+				`json schema violation at "" (#/uniqueItems): items at positions 1 and 2 are equal`,
+				`json schema violation at "" (#/contains): got 0 matching items, want at least 1`,
+			},
+		},
+		{
+			name:     "unevaluated items with contains",
+			schema:   `{"contains":{"type":"string"},"unevaluatedItems":false}`,
+			response: `["a",1,"b"]`,
+			wantErrors: []string{
+				`json schema violation at "" (#/unevaluatedItems): unevaluated item at position 1 is not allowed`,
+			},
+		},
+		{
+			name:     "dynamic reference",
+			schema:   `{"$id":"https://example.com/root","$ref":"list","$defs":{"items":{"$dynamicAnchor":"items","type":"integer"},"list":{"$id":"list","type":"array","items":{"$dynamicRef":"#items"},"$defs":{"items":{"$dynamicAnchor":"items"}}}}}`,
+			response: `[1,"a"]`,
+			wantErrors: []string{
+				`json schema violation at "/1" (#/$ref/items/$dynamicRef/type): got string, want integer`,
+			},
+		},
+		{
+			name:     "dynamic reference without dynamic anchor in scope",
+			schema:   `{"$id":"https://example.com/root","$ref":"list","$defs":{"list":{"$id":"list","type":"array","items":{"$dynamicRef":"#items"},"$defs":{"items":{"$dynamicAnchor":"items","type":"string"}}}}}`,
+			response: `["a",1]`,
+			wantErrors: []string{
+				`json schema violation at "/1" (#/$ref/items/$dynamicRef/type): got integer, want string`,
+			},
+		},
+		{
+			name:     "invalid json",
+			schema:   `true`,
+			response: `{"a":`,
+			wantErrors: []string{
+				`got invalid json response "{\"a\":": unexpected EOF`,
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, endpoint := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, tc.response)
+			}))
+
+			m := new(mock)
+			httpapitest.Request(m, c, http.MethodGet, endpoint,
+				httpapitest.ExpectJSONSchema([]byte(tc.schema)),
+			)
+			if !reflect.DeepEqual(m.gotErrors, tc.wantErrors) {
+				t.Errorf("got errors %q, want %q", m.gotErrors, tc.wantErrors)
+			}
+		})
+	}
+}
+
+func TestExpectJSONSchema_invalidSchema(t *testing.T) {
+
+	c, endpoint := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	assert(t, "", "json schema: #/properties/name: got string, want object or boolean schema", func(m *mock) {
+		httpapitest.Request(m, c, http.MethodGet, endpoint,
+			httpapitest.ExpectJSONSchema([]byte(`{"properties":{"name":"string"}}`)),
+		)
+	})
+}
+
+func TestExpectJSONSchemaFile(t *testing.T) {
+
+	filename := filepath.Join(t.TempDir(), "schema.json")
+	if err := os.WriteFile(filename, []byte(`{"type":"object","required":["message"]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	c, endpoint := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		respondJSON(w, http.StatusOK, "text")
+	}))
+
+	var r jsonStatusResponse
+	assert(t, "", "", func(m *mock) {
+		httpapitest.Request(m, c, http.MethodGet, endpoint,
+			httpapitest.ExpectJSONSchemaFile(filename),
+			httpapitest.UnmarshalJSONResponse(&r),
+		)
+	})
+	if r.Message != "text" {
+		t.Errorf("got message %q, want %q", r.Message, "text")
+	}
+
+	assert(t, `json schema violation at "" (#/required): missing required property "missing"`, "", func(m *mock) {
+		httpapitest.Request(m, c, http.MethodGet, endpoint,
+			httpapitest.ExpectJSONSchema([]byte(`{"required":["missing"]}`)),
+		)
+	})
+}
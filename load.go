@@ -0,0 +1,263 @@
+// Copyright (c) 2023, Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httpapitest
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// LoadConfig configures the Load function.
+type LoadConfig struct {
+	// Requests is the total number of requests to make. If zero, a single
+	// request is made.
+	Requests int
+	// Concurrency is the maximal number of requests in flight. If zero, it is
+	// one, or unlimited if Rate is set.
+	Concurrency int
+	// Rate is the target number of requests started per second. If zero,
+	// requests are started as soon as possible.
+	Rate float64
+	// Expectations are validated against the aggregated result after all
+	// requests complete.
+	Expectations []LoadExpectation
+}
+
+// LoadExpectation validates the aggregated result of the Load function. It
+// returns an error that describes the failed validation.
+type LoadExpectation func(r *LoadResult) error
+
+// ExpectLatencyPercentile validates that the latency percentile p, between 0
+// and 100, of all requests is at most max. For example, ExpectLatencyPercentile
+// (99, 200*time.Millisecond) expects p99 latency to be at most 200ms.
+func ExpectLatencyPercentile(p float64, max time.Duration) LoadExpectation {
+	return func(r *LoadResult) error {
+		if got := r.Percentile(p); got > max {
+			return fmt.Errorf("got p%v latency %v, want at most %v", p, got, max)
+		}
+		return nil
+	}
+}
+
+// ExpectMaxServerErrors validates that at most n responses have 5xx status
+// codes.
+func ExpectMaxServerErrors(n int) LoadExpectation {
+	return func(r *LoadResult) error {
+		var got int
+		for code, count := range r.StatusCounts {
+			if code >= 500 && code < 600 {
+				got += count
+			}
+		}
+		if got > n {
+			return fmt.Errorf("got %v responses with server error status, want at most %v", got, n)
+		}
+		return nil
+	}
+}
+
+// LoadResult is the aggregated result of requests made by the Load function.
+type LoadResult struct {
+	// Requests is the number of requests made.
+	Requests int
+	// Duration is the time from the start of the first request until the
+	// completion of the last one.
+	Duration time.Duration
+	// StatusCounts is the number of responses by their status codes.
+	StatusCounts map[int]int
+	// Failed is the number of requests with failed validations or errors.
+	Failed int
+	// Failures is the number of occurrences of every distinct failed
+	// validation or error message.
+	Failures map[string]int
+	// Latencies are durations of all requests in ascending order.
+	Latencies []time.Duration
+}
+
+// Percentile returns the latency percentile p, between 0 and 100, of all
+// requests, using the nearest-rank method.
+func (r *LoadResult) Percentile(p float64) time.Duration {
+	if len(r.Latencies) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(p/100*float64(len(r.Latencies)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(r.Latencies) {
+		i = len(r.Latencies) - 1
+	}
+	return r.Latencies[i]
+}
+
+// Rate returns the number of completed requests per second.
+func (r *LoadResult) Rate() float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+	return float64(r.Requests) / r.Duration.Seconds()
+}
+
+// String returns a summary of the result with status code counts and latency
+// percentiles.
+func (r *LoadResult) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "load: %v requests in %v (%.1f/s), %v failed",
+		r.Requests, r.Duration.Round(time.Millisecond), r.Rate(), r.Failed)
+	codes := make([]int, 0, len(r.StatusCounts))
+	for code := range r.StatusCounts {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	b.WriteString("\nstatus:")
+	for _, code := range codes {
+		fmt.Fprintf(&b, " %v: %v", code, r.StatusCounts[code])
+	}
+	fmt.Fprintf(&b, "\nlatency: p50 %v, p90 %v, p99 %v, max %v",
+		r.Percentile(50), r.Percentile(90), r.Percentile(99), r.Percentile(100))
+	return b.String()
+}
+
+func (r *LoadResult) add(res *result) {
+	r.Requests++
+	r.Latencies = append(r.Latencies, res.duration)
+	if resp := res.exchange.response; resp != nil {
+		r.StatusCounts[resp.StatusCode]++
+	}
+	if !res.failed() {
+		return
+	}
+	r.Failed++
+	for _, f := range res.failures {
+		r.Failures[f.Message]++
+	}
+	if res.err != nil {
+		r.Failures[res.err.Error()]++
+	}
+}
+
+// Load is a testing helper function that makes the same request as the
+// Request function, with the same options, multiple times concurrently or at
+// the target rate, as configured. It reports every distinct failed validation
+// of individual requests once with the number of its occurrences, and then
+// validates the aggregated result against the configured expectations. The
+// summary of the result is logged and returned. Options that log on failure,
+// WithDumpOnFailure and WithCurlOnFailure, have no effect, and options that
+// store response data, such as PutResponseBody, should not be used as they are
+// set by requests concurrently.
+func Load(t testing.TB, client *http.Client, method, url string, config LoadConfig, opts ...Option) *LoadResult {
+	t.Helper()
+
+	o, err := newOptions(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Requests < 0 {
+		t.Fatal(errors.New("load: number of requests must not be negative"))
+	}
+	if config.Concurrency < 0 {
+		t.Fatal(errors.New("load: concurrency must not be negative"))
+	}
+	if config.Rate < 0 {
+		t.Fatal(errors.New("load: rate must not be negative"))
+	}
+	next, err := o.replayable()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requests := config.Requests
+	if requests == 0 {
+		requests = 1
+	}
+	concurrency := config.Concurrency
+	if concurrency == 0 {
+		if config.Rate > 0 {
+			concurrency = requests
+		} else {
+			concurrency = 1
+		}
+	}
+	if concurrency > requests {
+		concurrency = requests
+	}
+
+	jobs := make(chan struct{})
+	go func() {
+		defer close(jobs)
+
+		var tick <-chan time.Time
+		if config.Rate > 0 {
+			ticker := time.NewTicker(time.Duration(float64(time.Second) / config.Rate))
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+		for i := 0; i < requests; i++ {
+			if i > 0 && tick != nil {
+				<-tick
+			}
+			jobs <- struct{}{}
+		}
+	}()
+
+	var name string
+	if o.report != nil {
+		name = t.Name()
+	}
+	r := &LoadResult{
+		StatusCounts: make(map[int]int),
+		Failures:     make(map[string]int),
+	}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for range jobs {
+				o := next()
+				requestStart := time.Now()
+				res := o.do(client, method, url)
+				o.reportResult(name, method, url, requestStart, res)
+
+				mu.Lock()
+				r.add(res)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	r.Duration = time.Since(start)
+	sort.Slice(r.Latencies, func(i, j int) bool {
+		return r.Latencies[i] < r.Latencies[j]
+	})
+
+	t.Logf("%s", r)
+
+	messages := make([]string, 0, len(r.Failures))
+	for m := range r.Failures {
+		messages = append(messages, m)
+	}
+	sort.Strings(messages)
+	for _, m := range messages {
+		t.Errorf("%v of %v requests: %s", r.Failures[m], r.Requests, m)
+	}
+	for _, e := range config.Expectations {
+		if err := e(r); err != nil {
+			t.Errorf("load: %v", err)
+		}
+	}
+	return r
+}
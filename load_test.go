@@ -0,0 +1,148 @@
+// Copyright (c) 2023, Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httpapitest_test
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"resenje.org/httpapitest"
+)
+
+func TestLoad(t *testing.T) {
+
+	var calls, inFlight, maxInFlight int32
+	c, endpoint := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			m := atomic.LoadInt32(&maxInFlight)
+			if n <= m || atomic.CompareAndSwapInt32(&maxInFlight, m, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		if b, _ := io.ReadAll(r.Body); string(b) != "data" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if atomic.AddInt32(&calls, 1)%10 == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+	}))
+
+	var got *httpapitest.LoadResult
+	assert(t, "load: got 3 responses with server error status, want at most 0", "", func(m *mock) {
+		got = httpapitest.Load(m, c, http.MethodPost, endpoint, httpapitest.LoadConfig{
+			Requests:    30,
+			Concurrency: 4,
+			Expectations: []httpapitest.LoadExpectation{
+				httpapitest.ExpectLatencyPercentile(99, 10*time.Second),
+				httpapitest.ExpectMaxServerErrors(0),
+			},
+		},
+			httpapitest.WithRequestBody(strings.NewReader("data")),
+		)
+	})
+	if got.Requests != 30 {
+		t.Errorf("got %v requests, want 30", got.Requests)
+	}
+	if got.StatusCounts[http.StatusOK] != 27 || got.StatusCounts[http.StatusServiceUnavailable] != 3 {
+		t.Errorf("got status counts %v", got.StatusCounts)
+	}
+	if got.Failed != 0 {
+		t.Errorf("got %v failed requests, want 0", got.Failed)
+	}
+	if maxInFlight > 4 {
+		t.Errorf("got %v concurrent requests, want at most 4", maxInFlight)
+	}
+	if len(got.Latencies) != 30 || got.Percentile(50) > got.Percentile(99) {
+		t.Errorf("got latencies %v", got.Latencies)
+	}
+
+	assert(t, "3 of 30 requests: got response status 503 Service Unavailable, want 200 OK", "", func(m *mock) {
+		got = httpapitest.Load(m, c, http.MethodPost, endpoint, httpapitest.LoadConfig{
+			Requests:    30,
+			Concurrency: 4,
+		},
+			httpapitest.WithRequestBody(strings.NewReader("data")),
+			httpapitest.ExpectStatus(http.StatusOK),
+		)
+	})
+	if got.Failed != 3 {
+		t.Errorf("got %v failed requests, want 3", got.Failed)
+	}
+}
+
+func TestLoad_rate(t *testing.T) {
+
+	c, endpoint := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	var got *httpapitest.LoadResult
+	assert(t, "", "", func(m *mock) {
+		got = httpapitest.Load(m, c, http.MethodGet, endpoint, httpapitest.LoadConfig{
+			Requests: 5,
+			Rate:     100,
+		},
+			httpapitest.ExpectStatus(http.StatusOK),
+		)
+	})
+	if got.Duration < 40*time.Millisecond {
+		t.Errorf("got duration %v, want at least 40ms", got.Duration)
+	}
+}
+
+func TestLoad_latencyPercentile(t *testing.T) {
+
+	c, endpoint := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("slow") != "" {
+			time.Sleep(50 * time.Millisecond)
+		}
+	}))
+
+	assert(t, "", "", func(m *mock) {
+		httpapitest.Load(m, c, http.MethodGet, endpoint, httpapitest.LoadConfig{
+			Requests:     10,
+			Concurrency:  2,
+			Expectations: []httpapitest.LoadExpectation{httpapitest.ExpectLatencyPercentile(99, time.Second)},
+		})
+	})
+
+	var got *httpapitest.LoadResult
+	assert(t, "", "", func(m *mock) {
+		got = httpapitest.Load(m, c, http.MethodGet, endpoint+"?slow=1", httpapitest.LoadConfig{
+			Requests: 2,
+		})
+	})
+	err := httpapitest.ExpectLatencyPercentile(99, time.Millisecond)(got)
+	if err == nil || !strings.HasPrefix(err.Error(), "got p99 latency ") {
+		t.Errorf("got error %v", err)
+	}
+}
+
+func TestLoadResult_Percentile(t *testing.T) {
+
+	r := &httpapitest.LoadResult{}
+	for i := 1; i <= 100; i++ {
+		r.Latencies = append(r.Latencies, time.Duration(i)*time.Millisecond)
+	}
+	for p, want := range map[float64]time.Duration{
+		0:    time.Millisecond,
+		50:   50 * time.Millisecond,
+		99:   99 * time.Millisecond,
+		99.9: 100 * time.Millisecond,
+		100:  100 * time.Millisecond,
+	} {
+		if got := r.Percentile(p); got != want {
+			t.Errorf("got p%v %v, want %v", p, got, want)
+		}
+	}
+}
@@ -0,0 +1,662 @@
+// Copyright (c) 2023, Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httpapitest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// OpenAPI is an OpenAPI 3 document used to validate requests and responses
+// made by the Request function. Only documents encoded as JSON are supported.
+type OpenAPI struct {
+	schema     *jsonSchema
+	basePaths  []string
+	operations []*openAPIOperation
+	mu         sync.Mutex // protects coverage counters in operations
+}
+
+type openAPIOperation struct {
+	id         string
+	method     string
+	path       string
+	segments   []string
+	templates  int
+	location   string
+	operation  map[string]interface{}
+	parameters []openAPIParameter
+	requests   int
+	responses  map[string]int
+}
+
+type openAPIParameter struct {
+	name     string
+	in       string
+	required bool
+	schema   interface{}
+	location string
+}
+
+func (op *openAPIOperation) String() string {
+	if op.id != "" {
+		return fmt.Sprintf("%q (%s %s)", op.id, op.method, op.path)
+	}
+	return op.method + " " + op.path
+}
+
+// openAPIViolation describes a request or response that does not conform to
+// the OpenAPI document.
+type openAPIViolation struct {
+	operation string
+	message   string
+}
+
+func (v openAPIViolation) Error() string {
+	if v.operation == "" {
+		return "openapi: " + v.message
+	}
+	return fmt.Sprintf("openapi operation %s: %s", v.operation, v.message)
+}
+
+var openAPIMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// LoadOpenAPI parses a JSON-encoded OpenAPI 3 document. Schemas in OpenAPI 3.0
+// documents are validated with nullable and boolean exclusiveMinimum and
+// exclusiveMaximum keywords of the 3.0 schema object. Variables in server URLs
+// are substituted by their default values.
+func LoadOpenAPI(data []byte) (*OpenAPI, error) {
+	root, err := decodeJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("openapi: %w", err)
+	}
+	doc, ok := root.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("openapi: got %s, want object document", jsonType(root))
+	}
+	version, _ := doc["openapi"].(string)
+	if !strings.HasPrefix(version, "3.") {
+		return nil, fmt.Errorf("openapi: unsupported version %q", version)
+	}
+
+	a := &OpenAPI{
+		schema: &jsonSchema{
+			root:           doc,
+			base:           defaultSchemaBaseURI,
+			resources:      map[string]schemaNode{defaultSchemaBaseURI: {schema: doc, base: defaultSchemaBaseURI}},
+			dynamicAnchors: make(map[string]schemaNode),
+			patterns:       make(map[string]*regexp.Regexp),
+			openAPI30:      strings.HasPrefix(version, "3.0"),
+		},
+	}
+
+	servers, _ := doc["servers"].([]interface{})
+	for _, s := range servers {
+		s, _ := s.(map[string]interface{})
+		u, err := serverURL(s)
+		if err != nil {
+			return nil, fmt.Errorf("openapi: %w", err)
+		}
+		p, err := url.Parse(u)
+		if err != nil {
+			return nil, fmt.Errorf("openapi: server url %q: %w", u, err)
+		}
+		if basePath := strings.TrimSuffix(p.Path, "/"); basePath != "" {
+			a.basePaths = append(a.basePaths, basePath)
+		}
+	}
+
+	if components, ok := doc["components"].(map[string]interface{}); ok {
+		if schemas, ok := components["schemas"].(map[string]interface{}); ok {
+			for name, schema := range schemas {
+				if err := a.schema.index(schema, defaultSchemaBaseURI, "/components/schemas/"+escapeJSONPointer(name)); err != nil {
+					return nil, fmt.Errorf("openapi: %w", err)
+				}
+			}
+		}
+	}
+
+	paths, _ := doc["paths"].(map[string]interface{})
+	for path, item := range paths {
+		item, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		itemLocation := "/paths/" + escapeJSONPointer(path)
+		item, itemLocation, err := a.deref(item, itemLocation)
+		if err != nil {
+			return nil, err
+		}
+		itemParameters, err := a.parameters(item, itemLocation)
+		if err != nil {
+			return nil, err
+		}
+		segments := strings.Split(strings.Trim(path, "/"), "/")
+		var templates int
+		for _, s := range segments {
+			if isPathTemplate(s) {
+				templates++
+			}
+		}
+		for _, method := range openAPIMethods {
+			operation, ok := item[method].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			location := itemLocation + "/" + method
+			parameters, err := a.parameters(operation, location)
+			if err != nil {
+				return nil, err
+			}
+			op := &openAPIOperation{
+				method:    strings.ToUpper(method),
+				path:      path,
+				segments:  segments,
+				templates: templates,
+				location:  location,
+				operation: operation,
+			}
+			op.id, _ = operation["operationId"].(string)
+			// operation level parameters override path item level ones
+			for _, p := range itemParameters {
+				overridden := false
+				for _, q := range parameters {
+					if p.name == q.name && p.in == q.in {
+						overridden = true
+						break
+					}
+				}
+				if !overridden {
+					op.parameters = append(op.parameters, p)
+				}
+			}
+			op.parameters = append(op.parameters, parameters...)
+			if err := a.indexSchemas(operation, location); err != nil {
+				return nil, err
+			}
+			a.operations = append(a.operations, op)
+		}
+	}
+	sort.Slice(a.operations, func(i, j int) bool {
+		if a.operations[i].path != a.operations[j].path {
+			return a.operations[i].path < a.operations[j].path
+		}
+		return a.operations[i].method < a.operations[j].method
+	})
+
+	return a, nil
+}
+
+// serverURL returns the url of the server object with variables substituted by
+// their default values.
+func serverURL(server map[string]interface{}) (string, error) {
+	u, _ := server["url"].(string)
+	variables, _ := server["variables"].(map[string]interface{})
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(u, '{')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(u[start:], '}')
+		if end < 0 {
+			break
+		}
+		end += start
+		name := u[start+1 : end]
+		v, _ := variables[name].(map[string]interface{})
+		value, ok := v["default"].(string)
+		if !ok {
+			return "", fmt.Errorf("server url %q: variable %q has no default value", server["url"], name)
+		}
+		b.WriteString(u[:start])
+		b.WriteString(value)
+		u = u[end+1:]
+	}
+	b.WriteString(u)
+	return b.String(), nil
+}
+
+// LoadOpenAPIFile reads and parses a JSON-encoded OpenAPI 3 document from the
+// file.
+func LoadOpenAPIFile(filename string) (*OpenAPI, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("read openapi document: %w", err)
+	}
+	a, err := LoadOpenAPI(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return a, nil
+}
+
+// WithOpenAPI validates that the request made by the Request function and its
+// response conform to the OpenAPI document. The request method and path must
+// match an operation in the document, request parameters and body must be
+// valid against their schemas, and the response status code, headers and body
+// must be declared by the operation responses. Set this option as a Client
+// default option to validate every request made by the client.
+func WithOpenAPI(a *OpenAPI) Option {
+	return optionFunc(func(o *options) error {
+		o.openAPI = a
+		return nil
+	})
+}
+
+// validate validates the request and response exchange and returns all found
+// contract violations.
+func (a *OpenAPI) validate(r *http.Request, requestBody []byte, resp *http.Response, responseBody []byte, streamed bool) []error {
+	op, pathParams := a.operation(r.Method, r.URL.Path)
+	if op == nil {
+		return []error{openAPIViolation{message: fmt.Sprintf("undeclared operation %s %s", r.Method, r.URL.Path)}}
+	}
+	var errs []error
+	fail := func(format string, a ...interface{}) {
+		errs = append(errs, openAPIViolation{operation: op.String(), message: fmt.Sprintf(format, a...)})
+	}
+
+	query := r.URL.Query()
+	for _, p := range op.parameters {
+		var values []string
+		switch p.in {
+		case "path":
+			if v, ok := pathParams[p.name]; ok {
+				values = []string{v}
+			}
+		case "query":
+			values = query[p.name]
+		case "header":
+			values = r.Header.Values(p.name)
+		case "cookie":
+			if c, err := r.Cookie(p.name); err == nil {
+				values = []string{c.Value}
+			}
+		}
+		if len(values) == 0 {
+			if p.required {
+				fail("missing required %s parameter %q", p.in, p.name)
+			}
+			continue
+		}
+		if p.schema == nil {
+			continue
+		}
+		for _, v := range a.schema.validateAt(p.schema, a.parameterValue(p.schema, values), p.location+"/schema") {
+			fail("%s parameter %q: %v", p.in, p.name, v)
+		}
+	}
+
+	if rb, ok := op.operation["requestBody"].(map[string]interface{}); ok {
+		rb, location, err := a.deref(rb, op.location+"/requestBody")
+		if err != nil {
+			fail("request body: %v", err)
+		} else if len(requestBody) == 0 {
+			if required, _ := rb["required"].(bool); required {
+				fail("missing required request body")
+			}
+		} else {
+			for _, err := range a.validateContent(rb, location, r.Header.Get("Content-Type"), requestBody, false) {
+				fail("request %v", err)
+			}
+		}
+	}
+
+	responses, _ := op.operation["responses"].(map[string]interface{})
+	response, location := openAPIResponse(responses, resp.StatusCode)
+	a.record(op, location)
+	if response == nil {
+		fail("undeclared response status %s", resp.Status)
+		return errs
+	}
+	response, location, err := a.deref(response, op.location+"/responses/"+location)
+	if err != nil {
+		fail("response: %v", err)
+		return errs
+	}
+	headers, _ := response["headers"].(map[string]interface{})
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if strings.EqualFold(name, "Content-Type") {
+			continue
+		}
+		header, _ := headers[name].(map[string]interface{})
+		header, headerLocation, err := a.deref(header, location+"/headers/"+escapeJSONPointer(name))
+		if err != nil {
+			fail("response header %q: %v", name, err)
+			continue
+		}
+		values := resp.Header.Values(name)
+		if len(values) == 0 {
+			if required, _ := header["required"].(bool); required {
+				fail("missing required response header %q", name)
+			}
+			continue
+		}
+		schema, ok := header["schema"]
+		if !ok {
+			continue
+		}
+		for _, v := range a.schema.validateAt(schema, a.parameterValue(schema, values), headerLocation+"/schema") {
+			fail("response header %q: %v", name, v)
+		}
+	}
+	if len(responseBody) > 0 || streamed {
+		for _, err := range a.validateContent(response, location, resp.Header.Get("Content-Type"), responseBody, streamed) {
+			fail("response %v", err)
+		}
+	}
+
+	return errs
+}
+
+// operation finds the operation that matches the method and path, preferring
+// operations with fewer templated path segments.
+func (a *OpenAPI) operation(method, path string) (op *openAPIOperation, params map[string]string) {
+	paths := []string{path}
+	for _, basePath := range a.basePaths {
+		if p := strings.TrimPrefix(path, basePath); p != path && (p == "" || p[0] == '/') {
+			paths = append(paths, p)
+		}
+	}
+	for _, path := range paths {
+		segments := strings.Split(strings.Trim(path, "/"), "/")
+		for _, o := range a.operations {
+			if o.method != method || len(o.segments) != len(segments) {
+				continue
+			}
+			if op != nil && o.templates >= op.templates {
+				continue
+			}
+			p, ok := matchPathSegments(o.segments, segments)
+			if !ok {
+				continue
+			}
+			op, params = o, p
+		}
+		if op != nil {
+			return op, params
+		}
+	}
+	return nil, nil
+}
+
+func matchPathSegments(template, segments []string) (map[string]string, bool) {
+	params := make(map[string]string)
+	for i, t := range template {
+		s, err := url.PathUnescape(segments[i])
+		if err != nil {
+			s = segments[i]
+		}
+		if isPathTemplate(t) {
+			if s == "" {
+				return nil, false
+			}
+			params[t[1:len(t)-1]] = s
+			continue
+		}
+		if t != s {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+func isPathTemplate(segment string) bool {
+	return len(segment) > 2 && segment[0] == '{' && segment[len(segment)-1] == '}'
+}
+
+// openAPIResponse returns the response object for the status code, trying the
+// exact code, the code range and the default response, in that order.
+func openAPIResponse(responses map[string]interface{}, code int) (response map[string]interface{}, key string) {
+	status := strconv.Itoa(code)
+	for _, key := range []string{status, status[:1] + "XX", status[:1] + "xx", "default"} {
+		if r, ok := responses[key].(map[string]interface{}); ok {
+			return r, key
+		}
+	}
+	return nil, ""
+}
+
+// validateContent validates the body against the content of the request body
+// or response object. Only bodies with JSON media types are validated against
+// schemas.
+// validateContent validates the body against the schema of the content type
+// declared in the object. If the body is streamed, it is not available, and
+// only the content type is validated.
+func (a *OpenAPI) validateContent(object map[string]interface{}, location, contentType string, body []byte, streamed bool) []error {
+	content, ok := object["content"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return []error{fmt.Errorf("content type %q: %w", contentType, err)}
+	}
+	key := ""
+	if _, ok := content[mediaType]; ok {
+		key = mediaType
+	} else if i := strings.IndexByte(mediaType, '/'); i >= 0 {
+		if _, ok := content[mediaType[:i]+"/*"]; ok {
+			key = mediaType[:i] + "/*"
+		} else if _, ok := content["*/*"]; ok {
+			key = "*/*"
+		}
+	}
+	if key == "" {
+		return []error{fmt.Errorf("content type %q is not declared", mediaType)}
+	}
+	media, _ := content[key].(map[string]interface{})
+	schema, ok := media["schema"]
+	if !ok || !isJSONMediaType(mediaType) {
+		return nil
+	}
+	if streamed {
+		return []error{errors.New("body: streamed response body is not validated against the schema")}
+	}
+	instance, err := decodeJSON(body)
+	if err != nil {
+		return []error{fmt.Errorf("body: invalid json: %w", err)}
+	}
+	var errs []error
+	for _, v := range a.schema.validateAt(schema, instance, location+"/content/"+escapeJSONPointer(key)+"/schema") {
+		errs = append(errs, fmt.Errorf("body: %w", v))
+	}
+	return errs
+}
+
+func isJSONMediaType(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// parameters returns parameters declared by the path item or operation object.
+func (a *OpenAPI) parameters(object map[string]interface{}, location string) ([]openAPIParameter, error) {
+	list, _ := object["parameters"].([]interface{})
+	parameters := make([]openAPIParameter, 0, len(list))
+	for i, p := range list {
+		p, _ := p.(map[string]interface{})
+		p, l, err := a.deref(p, location+"/parameters/"+strconv.Itoa(i))
+		if err != nil {
+			return nil, err
+		}
+		name, _ := p["name"].(string)
+		in, _ := p["in"].(string)
+		if name == "" || in == "" {
+			return nil, fmt.Errorf("openapi: %s: parameter name and location are required", schemaLocation(l))
+		}
+		required, _ := p["required"].(bool)
+		schema := p["schema"]
+		if schema != nil {
+			if err := a.schema.index(schema, defaultSchemaBaseURI, l+"/schema"); err != nil {
+				return nil, fmt.Errorf("openapi: %w", err)
+			}
+		}
+		parameters = append(parameters, openAPIParameter{
+			name:     name,
+			in:       in,
+			required: required || in == "path",
+			schema:   schema,
+			location: l,
+		})
+	}
+	return parameters, nil
+}
+
+// indexSchemas prepares all inline schemas of the operation request body and
+// responses for validation.
+func (a *OpenAPI) indexSchemas(operation map[string]interface{}, location string) error {
+	var objects []map[string]interface{}
+	var locations []string
+	if rb, ok := operation["requestBody"].(map[string]interface{}); ok {
+		objects = append(objects, rb)
+		locations = append(locations, location+"/requestBody")
+	}
+	responses, _ := operation["responses"].(map[string]interface{})
+	for key, r := range responses {
+		if r, ok := r.(map[string]interface{}); ok {
+			objects = append(objects, r)
+			locations = append(locations, location+"/responses/"+escapeJSONPointer(key))
+		}
+	}
+	for i, object := range objects {
+		object, l, err := a.deref(object, locations[i])
+		if err != nil {
+			return err
+		}
+		content, _ := object["content"].(map[string]interface{})
+		for key, media := range content {
+			media, _ := media.(map[string]interface{})
+			if schema, ok := media["schema"]; ok {
+				if err := a.schema.index(schema, defaultSchemaBaseURI, l+"/content/"+escapeJSONPointer(key)+"/schema"); err != nil {
+					return fmt.Errorf("openapi: %w", err)
+				}
+			}
+		}
+		headers, _ := object["headers"].(map[string]interface{})
+		for name, h := range headers {
+			h, _ := h.(map[string]interface{})
+			h, hl, err := a.deref(h, l+"/headers/"+escapeJSONPointer(name))
+			if err != nil {
+				return err
+			}
+			if schema, ok := h["schema"]; ok {
+				if err := a.schema.index(schema, defaultSchemaBaseURI, hl+"/schema"); err != nil {
+					return fmt.Errorf("openapi: %w", err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// deref follows $ref of OpenAPI objects that are not schemas, returning the
+// referenced object and its location in the document.
+func (a *OpenAPI) deref(object map[string]interface{}, location string) (map[string]interface{}, string, error) {
+	for i := 0; i < maxSchemaDepth; i++ {
+		ref, ok := object["$ref"].(string)
+		if !ok {
+			return object, location, nil
+		}
+		if !strings.HasPrefix(ref, "#/") {
+			return nil, "", fmt.Errorf("openapi: %s: unsupported reference %q", schemaLocation(location), ref)
+		}
+		n, err := a.schema.resolve(defaultSchemaBaseURI, ref)
+		if err != nil {
+			return nil, "", fmt.Errorf("openapi: %s: %w", schemaLocation(location), err)
+		}
+		object, ok = n.schema.(map[string]interface{})
+		if !ok {
+			return nil, "", fmt.Errorf("openapi: %s: reference %q is not an object", schemaLocation(location), ref)
+		}
+		location = ref[1:]
+	}
+	return nil, "", errors.New("openapi: maximum reference depth exceeded")
+}
+
+// parameterValue converts string parameter values to the JSON value that can
+// be validated against the parameter schema.
+func (a *OpenAPI) parameterValue(schema interface{}, values []string) interface{} {
+	typ := a.schemaType(schema)
+	if typ == "array" {
+		var items interface{}
+		if m, ok := a.derefSchema(schema).(map[string]interface{}); ok {
+			items = m["items"]
+		}
+		if len(values) == 1 {
+			values = strings.Split(values[0], ",")
+		}
+		v := make([]interface{}, 0, len(values))
+		for _, value := range values {
+			v = append(v, parseParameterValue(a.schemaType(items), value))
+		}
+		return v
+	}
+	return parseParameterValue(typ, values[0])
+}
+
+func parseParameterValue(typ, value string) interface{} {
+	switch typ {
+	case "integer", "number":
+		if _, err := strconv.ParseFloat(value, 64); err == nil {
+			return json.Number(value)
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return value
+}
+
+func (a *OpenAPI) schemaType(schema interface{}) string {
+	m, ok := a.derefSchema(schema).(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	switch t := m["type"].(type) {
+	case string:
+		return t
+	case []interface{}:
+		for _, t := range t {
+			if t, ok := t.(string); ok && t != "null" {
+				return t
+			}
+		}
+	}
+	return ""
+}
+
+func (a *OpenAPI) derefSchema(schema interface{}) interface{} {
+	for i := 0; i < maxSchemaDepth; i++ {
+		m, ok := schema.(map[string]interface{})
+		if !ok {
+			return schema
+		}
+		ref, ok := m["$ref"].(string)
+		if !ok {
+			return schema
+		}
+		n, err := a.schema.resolve(defaultSchemaBaseURI, ref)
+		if err != nil {
+			return schema
+		}
+		schema = n.schema
+	}
+	return schema
+}
@@ -0,0 +1,187 @@
+// Copyright (c) 2023, Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httpapitest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// OpenAPICoverage holds information about which operations and their declared
+// responses from the OpenAPI document were exercised by requests validated
+// with the WithOpenAPI option.
+type OpenAPICoverage struct {
+	Operations        []OpenAPIOperationCoverage `json:"operations"`
+	OperationsTotal   int                        `json:"operationsTotal"`
+	OperationsCovered int                        `json:"operationsCovered"`
+	ResponsesTotal    int                        `json:"responsesTotal"`
+	ResponsesCovered  int                        `json:"responsesCovered"`
+}
+
+// OpenAPIOperationCoverage holds the number of requests made to a single
+// operation and the number of received responses for each of its declared
+// response status codes.
+type OpenAPIOperationCoverage struct {
+	OperationID string                    `json:"operationId,omitempty"`
+	Method      string                    `json:"method"`
+	Path        string                    `json:"path"`
+	Requests    int                       `json:"requests"`
+	Responses   []OpenAPIResponseCoverage `json:"responses"`
+}
+
+// OpenAPIResponseCoverage holds the number of responses received for a
+// declared response status code, status code range or the default response.
+type OpenAPIResponseCoverage struct {
+	Status   string `json:"status"`
+	Requests int    `json:"requests"`
+}
+
+// Coverage returns operations and responses coverage of all requests that
+// were validated against the OpenAPI document until now.
+func (a *OpenAPI) Coverage() OpenAPICoverage {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	c := OpenAPICoverage{
+		Operations:      make([]OpenAPIOperationCoverage, 0, len(a.operations)),
+		OperationsTotal: len(a.operations),
+	}
+	for _, op := range a.operations {
+		oc := OpenAPIOperationCoverage{
+			OperationID: op.id,
+			Method:      op.method,
+			Path:        op.path,
+			Requests:    op.requests,
+		}
+		if op.requests > 0 {
+			c.OperationsCovered++
+		}
+		responses, _ := op.operation["responses"].(map[string]interface{})
+		statuses := make([]string, 0, len(responses))
+		for status := range responses {
+			statuses = append(statuses, status)
+		}
+		sort.Strings(statuses)
+		for _, status := range statuses {
+			requests := op.responses[status]
+			oc.Responses = append(oc.Responses, OpenAPIResponseCoverage{
+				Status:   status,
+				Requests: requests,
+			})
+			c.ResponsesTotal++
+			if requests > 0 {
+				c.ResponsesCovered++
+			}
+		}
+		c.Operations = append(c.Operations, oc)
+	}
+	return c
+}
+
+// WriteSummary writes a human readable coverage summary that lists all
+// operations and responses that were never received.
+func (c OpenAPICoverage) WriteSummary(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "openapi coverage: operations %v/%v (%s), responses %v/%v (%s)\n",
+		c.OperationsCovered, c.OperationsTotal, percent(c.OperationsCovered, c.OperationsTotal),
+		c.ResponsesCovered, c.ResponsesTotal, percent(c.ResponsesCovered, c.ResponsesTotal),
+	); err != nil {
+		return err
+	}
+	var operations, responses []string
+	for _, op := range c.Operations {
+		name := op.Method + " " + op.Path
+		if op.OperationID != "" {
+			name += " (" + op.OperationID + ")"
+		}
+		if op.Requests == 0 {
+			operations = append(operations, name)
+		}
+		for _, r := range op.Responses {
+			if r.Requests == 0 {
+				responses = append(responses, name+": "+r.Status)
+			}
+		}
+	}
+	for _, l := range []struct {
+		title string
+		items []string
+	}{
+		{title: "operations not covered", items: operations},
+		{title: "responses not covered", items: responses},
+	} {
+		if len(l.items) == 0 {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "%s:\n", l.title); err != nil {
+			return err
+		}
+		for _, item := range l.items {
+			if _, err := fmt.Fprintf(w, "\t%s\n", item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// WriteCoverageReport writes the JSON-encoded coverage to the file with the
+// provided filename and the human readable summary to the summary writer. If
+// the filename is empty, the JSON report is not written and if the writer is
+// nil, the summary is not written. It is intended to be called in the TestMain
+// function after all tests are run:
+//
+//	func TestMain(m *testing.M) {
+//		code := m.Run()
+//		if err := api.WriteCoverageReport("openapi-coverage.json", os.Stdout); err != nil {
+//			fmt.Fprintln(os.Stderr, err)
+//			code = 1
+//		}
+//		os.Exit(code)
+//	}
+func (a *OpenAPI) WriteCoverageReport(filename string, summary io.Writer) error {
+	c := a.Coverage()
+	if filename != "" {
+		data, err := json.MarshalIndent(c, "", "\t")
+		if err != nil {
+			return fmt.Errorf("json encode openapi coverage: %w", err)
+		}
+		if err := os.WriteFile(filename, append(data, '\n'), 0o666); err != nil {
+			return fmt.Errorf("write openapi coverage: %w", err)
+		}
+	}
+	if summary != nil {
+		if err := c.WriteSummary(summary); err != nil {
+			return fmt.Errorf("write openapi coverage summary: %w", err)
+		}
+	}
+	return nil
+}
+
+// record counts a request to the operation and the response status key, if
+// it is declared.
+func (a *OpenAPI) record(op *openAPIOperation, status string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	op.requests++
+	if status == "" {
+		return
+	}
+	if op.responses == nil {
+		op.responses = make(map[string]int)
+	}
+	op.responses[status]++
+}
+
+func percent(n, total int) string {
+	if total == 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.1f%%", float64(n)*100/float64(total))
+}
@@ -0,0 +1,114 @@
+// Copyright (c) 2023, Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httpapitest_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"resenje.org/httpapitest"
+)
+
+func TestOpenAPI_Coverage(t *testing.T) {
+
+	doc, err := httpapitest.LoadOpenAPI([]byte(testOpenAPIDocument))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, endpoint := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/v1/users/me" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"message":"bad"}`))
+	}))
+
+	client := httpapitest.NewClient(c, httpapitest.WithOpenAPI(doc))
+
+	for i := 0; i < 2; i++ {
+		client.Request(t, http.MethodGet, endpoint+"/v1/users/me")
+	}
+	client.Request(t, http.MethodPost, endpoint+"/v1/users",
+		httpapitest.WithJSONRequestBody(map[string]string{"name": "test"}),
+		httpapitest.WithRequestHeader("Content-Type", "application/json"),
+	)
+
+	want := httpapitest.OpenAPICoverage{
+		Operations: []httpapitest.OpenAPIOperationCoverage{
+			{
+				OperationID: "createUser",
+				Method:      http.MethodPost,
+				Path:        "/users",
+				Requests:    1,
+				Responses: []httpapitest.OpenAPIResponseCoverage{
+					{Status: "201"},
+					{Status: "4XX", Requests: 1},
+				},
+			},
+			{
+				Method:   http.MethodGet,
+				Path:     "/users/me",
+				Requests: 2,
+				Responses: []httpapitest.OpenAPIResponseCoverage{
+					{Status: "204", Requests: 2},
+				},
+			},
+			{
+				OperationID: "getUser",
+				Method:      http.MethodGet,
+				Path:        "/users/{id}",
+				Responses: []httpapitest.OpenAPIResponseCoverage{
+					{Status: "200"},
+				},
+			},
+		},
+		OperationsTotal:   3,
+		OperationsCovered: 2,
+		ResponsesTotal:    4,
+		ResponsesCovered:  2,
+	}
+
+	if got := doc.Coverage(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got coverage %+v, want %+v", got, want)
+	}
+
+	filename := filepath.Join(t.TempDir(), "coverage.json")
+	var summary bytes.Buffer
+	if err := doc.WriteCoverageReport(filename, &summary); err != nil {
+		t.Fatal(err)
+	}
+
+	wantSummary := `openapi coverage: operations 2/3 (66.7%), responses 2/4 (50.0%)
+operations not covered:
+	GET /users/{id} (getUser)
+responses not covered:
+	POST /users (createUser): 201
+	GET /users/{id} (getUser): 200
+`
+	if got := summary.String(); got != wantSummary {
+		t.Errorf("got summary %q, want %q", got, wantSummary)
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got httpapitest.OpenAPICoverage
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got json coverage %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,337 @@
+// Copyright (c) 2023, Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httpapitest_test
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"resenje.org/httpapitest"
+)
+
+const testOpenAPIDocument = `{
+	"openapi": "3.1.0",
+	"info": {"title": "test", "version": "1.0.0"},
+	"servers": [{"url": "https://api.example.com/v1"}],
+	"paths": {
+		"/users": {
+			"post": {
+				"operationId": "createUser",
+				"requestBody": {
+					"required": true,
+					"content": {"application/json": {"schema": {"$ref": "#/components/schemas/User"}}}
+				},
+				"responses": {
+					"201": {
+						"description": "created",
+						"headers": {"Location": {"required": true, "schema": {"type": "string", "pattern": "^/v1/users/[0-9]+$"}}},
+						"content": {"application/json": {"schema": {"$ref": "#/components/schemas/User"}}}
+					},
+					"4XX": {"$ref": "#/components/responses/Error"}
+				}
+			}
+		},
+		"/users/{id}": {
+			"parameters": [{"name": "id", "in": "path", "required": true, "schema": {"type": "integer"}}],
+			"get": {
+				"operationId": "getUser",
+				"parameters": [{"name": "fields", "in": "query", "schema": {"type": "array", "items": {"enum": ["id", "name"]}}}],
+				"responses": {
+					"200": {
+						"description": "user",
+						"content": {"application/json": {"schema": {"$ref": "#/components/schemas/User"}}}
+					}
+				}
+			}
+		},
+		"/users/me": {
+			"get": {
+				"responses": {"204": {"description": "no content"}}
+			}
+		}
+	},
+	"components": {
+		"schemas": {
+			"User": {
+				"type": "object",
+				"properties": {"id": {"type": "integer"}, "name": {"type": "string"}},
+				"required": ["name"]
+			}
+		},
+		"responses": {
+			"Error": {
+				"description": "error",
+				"content": {"application/json": {"schema": {"type": "object", "required": ["message"]}}}
+			}
+		}
+	}
+}`
+
+func TestWithOpenAPI(t *testing.T) {
+
+	doc, err := httpapitest.LoadOpenAPI([]byte(testOpenAPIDocument))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, endpoint := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/users":
+			if r.URL.Query().Get("fail") != "" {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"error":"bad"}`)
+				return
+			}
+			w.Header().Set("Location", "/v1/users/1")
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, `{"id":1,"name":"test"}`)
+		case r.URL.Path == "/v1/users/me":
+			w.WriteHeader(http.StatusNoContent)
+		case r.URL.Path == "/v1/users/1":
+			fmt.Fprint(w, `{"id":"1","name":"test"}`)
+		case r.URL.Path == "/v1/users/2":
+			fmt.Fprint(w, `{"id":2,"name":"test"}`)
+		default:
+			w.WriteHeader(http.StatusTeapot)
+		}
+	}))
+
+	client := httpapitest.NewClient(c, httpapitest.WithOpenAPI(doc))
+
+	for _, tc := range []struct {
+		name       string
+		method     string
+		path       string
+		opts       []httpapitest.Option
+		wantErrors []string
+	}{
+		{
+			name:   "valid",
+			method: http.MethodPost,
+			path:   "/v1/users",
+			opts: []httpapitest.Option{
+				httpapitest.WithJSONRequestBody(map[string]interface{}{"name": "test"}),
+				httpapitest.WithRequestHeader("Content-Type", "application/json"),
+			},
+		},
+		{
+			name:   "literal path preferred",
+			method: http.MethodGet,
+			path:   "/v1/users/me",
+		},
+		{
+			name:   "valid parameters",
+			method: http.MethodGet,
+			path:   "/v1/users/2?fields=id,name",
+		},
+		{
+			name:   "undeclared operation",
+			method: http.MethodDelete,
+			path:   "/v1/users/1",
+			wantErrors: []string{
+				"openapi: undeclared operation DELETE /v1/users/1",
+			},
+		},
+		{
+			name:   "invalid request",
+			method: http.MethodPost,
+			path:   "/v1/users",
+			opts: []httpapitest.Option{
+				httpapitest.WithRequestBody(strings.NewReader(`{"id":1}`)),
+				httpapitest.WithRequestHeader("Content-Type", "application/json"),
+			},
+			wantErrors: []string{
+				`openapi operation "createUser" (POST /users): request body: json schema violation at "" (#/paths/~1users/post/requestBody/content/application~1json/schema/$ref/required): missing required property "name"`,
+			},
+		},
+		{
+			name:   "missing request body",
+			method: http.MethodPost,
+			path:   "/v1/users",
+			wantErrors: []string{
+				`openapi operation "createUser" (POST /users): missing required request body`,
+			},
+		},
+		{
+			name:   "undeclared request content type",
+			method: http.MethodPost,
+			path:   "/v1/users",
+			opts: []httpapitest.Option{
+				httpapitest.WithRequestBody(strings.NewReader(`name=test`)),
+				httpapitest.WithRequestHeader("Content-Type", "application/x-www-form-urlencoded"),
+			},
+			wantErrors: []string{
+				`openapi operation "createUser" (POST /users): request content type "application/x-www-form-urlencoded" is not declared`,
+			},
+		},
+		{
+			name:   "invalid error response",
+			method: http.MethodPost,
+			path:   "/v1/users?fail=1",
+			opts: []httpapitest.Option{
+				httpapitest.WithRequestBody(strings.NewReader(`{"name":"test"}`)),
+				httpapitest.WithRequestHeader("Content-Type", "application/json"),
+			},
+			wantErrors: []string{
+				`openapi operation "createUser" (POST /users): response body: json schema violation at "" (#/components/responses/Error/content/application~1json/schema/required): missing required property "message"`,
+			},
+		},
+		{
+			name:   "invalid parameters and response",
+			method: http.MethodGet,
+			path:   "/v1/users/1?fields=email",
+			wantErrors: []string{
+				`openapi operation "getUser" (GET /users/{id}): query parameter "fields": json schema violation at "/0" (#/paths/~1users~1{id}/get/parameters/0/schema/items/enum): got "email", want one of ["id","name"]`,
+				`openapi operation "getUser" (GET /users/{id}): response body: json schema violation at "/id" (#/paths/~1users~1{id}/get/responses/200/content/application~1json/schema/$ref/properties/id/type): got string, want integer`,
+			},
+		},
+		{
+			name:   "invalid path parameter and undeclared status",
+			method: http.MethodGet,
+			path:   "/v1/users/abc",
+			wantErrors: []string{
+				`openapi operation "getUser" (GET /users/{id}): path parameter "id": json schema violation at "" (#/paths/~1users~1{id}/parameters/0/schema/type): got string, want integer`,
+				`openapi operation "getUser" (GET /users/{id}): undeclared response status 418 I'm a teapot`,
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m := new(mock)
+			client.Request(m, tc.method, endpoint+tc.path, tc.opts...)
+			if !reflect.DeepEqual(m.gotErrors, tc.wantErrors) {
+				t.Errorf("got errors %q, want %q", m.gotErrors, tc.wantErrors)
+			}
+		})
+	}
+}
+
+func TestWithOpenAPI_version30(t *testing.T) {
+
+	doc, err := httpapitest.LoadOpenAPI([]byte(`{
+		"openapi": "3.0.3",
+		"paths": {
+			"/items": {
+				"get": {
+					"responses": {
+						"200": {
+							"description": "item",
+							"content": {"application/json": {"schema": {
+								"type": "object",
+								"properties": {
+									"name": {"type": "string", "nullable": true},
+									"count": {"type": "integer", "minimum": 0, "exclusiveMinimum": true, "maximum": 10, "exclusiveMaximum": true}
+								}
+							}}}
+						}
+					}
+				}
+			}
+		}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		response   string
+		wantErrors []string
+	}{
+		{
+			response: `{"name":null,"count":1}`,
+		},
+		{
+			response: `{"name":"test","count":0}`,
+			wantErrors: []string{
+				`openapi operation GET /items: response body: json schema violation at "/count" (#/paths/~1items/get/responses/200/content/application~1json/schema/properties/count/exclusiveMinimum): got 0, want greater than 0`,
+			},
+		},
+		{
+			response: `{"count":10}`,
+			wantErrors: []string{
+				`openapi operation GET /items: response body: json schema violation at "/count" (#/paths/~1items/get/responses/200/content/application~1json/schema/properties/count/exclusiveMaximum): got 10, want less than 10`,
+			},
+		},
+	} {
+		t.Run(tc.response, func(t *testing.T) {
+			c, endpoint := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprint(w, tc.response)
+			}))
+			m := new(mock)
+			httpapitest.Request(m, c, http.MethodGet, endpoint+"/items", httpapitest.WithOpenAPI(doc))
+			if !reflect.DeepEqual(m.gotErrors, tc.wantErrors) {
+				t.Errorf("got errors %q, want %q", m.gotErrors, tc.wantErrors)
+			}
+		})
+	}
+}
+
+func TestWithOpenAPI_serverVariables(t *testing.T) {
+
+	doc, err := httpapitest.LoadOpenAPI([]byte(`{
+		"openapi": "3.1.0",
+		"servers": [{
+			"url": "https://{env}.example.com/{version}",
+			"variables": {"env": {"default": "api"}, "version": {"default": "v2", "enum": ["v1", "v2"]}}
+		}],
+		"paths": {"/items": {"get": {"responses": {"204": {"description": "no content"}}}}}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, endpoint := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	assert(t, "", "", func(m *mock) {
+		httpapitest.Request(m, c, http.MethodGet, endpoint+"/v2/items", httpapitest.WithOpenAPI(doc))
+	})
+	assert(t, "openapi: undeclared operation GET /v1/items", "", func(m *mock) {
+		httpapitest.Request(m, c, http.MethodGet, endpoint+"/v1/items", httpapitest.WithOpenAPI(doc))
+	})
+}
+
+func TestLoadOpenAPI_invalid(t *testing.T) {
+
+	for _, tc := range []struct {
+		name    string
+		doc     string
+		wantErr string
+	}{
+		{
+			name:    "invalid json",
+			doc:     `{`,
+			wantErr: "openapi: unexpected EOF",
+		},
+		{
+			name:    "version",
+			doc:     `{"swagger":"2.0"}`,
+			wantErr: `openapi: unsupported version ""`,
+		},
+		{
+			name:    "server variable",
+			doc:     `{"openapi":"3.1.0","servers":[{"url":"https://{env}.example.com"}]}`,
+			wantErr: `openapi: server url "https://{env}.example.com": variable "env" has no default value`,
+		},
+		{
+			name:    "reference",
+			doc:     `{"openapi":"3.0.3","paths":{"/":{"get":{"parameters":[{"$ref":"#/components/parameters/missing"}]}}}}`,
+			wantErr: `openapi: #/paths/~1/get/parameters/0: unresolvable reference "#/components/parameters/missing"`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := httpapitest.LoadOpenAPI([]byte(tc.doc))
+			if err == nil || err.Error() != tc.wantErr {
+				t.Errorf("got error %v, want %v", err, tc.wantErr)
+			}
+		})
+	}
+}
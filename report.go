@@ -0,0 +1,332 @@
+// Copyright (c) 2023, Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httpapitest
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Report collects results of requests made by the Request and Check
+// functions, to be written in JUnit XML or JSON format. The zero value is ready
+// to use and it is safe for concurrent use.
+type Report struct {
+	mu      sync.Mutex
+	entries []ReportEntry
+}
+
+// ReportEntry is the result of a single request.
+type ReportEntry struct {
+	// Name is the name set with the WithName option or the name of the test
+	// in which the request is made by the Request function.
+	Name     string
+	Method   string
+	URL      string
+	Status   int
+	Start    time.Time
+	Duration time.Duration
+	// Timing is the timing of the last attempt.
+	Timing Timing
+	// Attempts is the number of times the request was made, with the
+	// Eventually option.
+	Attempts int
+	Failures []AssertionError
+	// Error is the error that stopped the validation, if any.
+	Error string
+}
+
+// Passed returns true if all validations passed.
+func (e ReportEntry) Passed() bool {
+	return len(e.Failures) == 0 && e.Error == ""
+}
+
+// NewReport returns a new Report that is written to a file when the test and
+// all its subtests complete. The file is written in JSON format if it has the
+// .json extension and in JUnit XML format otherwise. If the filename is empty,
+// it is derived from the test name with the .xml extension. Relative
+// filenames are placed in the directory specified by the
+// HTTPAPITEST_ARTIFACTS_DIR environment variable or, if it is not set, in a
+// new temporary directory only if the test failed. The path of the file is
+// logged if the test failed.
+func NewReport(t testing.TB, filename string) *Report {
+	t.Helper()
+
+	r := new(Report)
+	saveArtifact(t, filename, ".xml", "report file", r.WriteFile)
+	return r
+}
+
+// WithReport adds the result of the request made by the Request or Check
+// function to the Report. Set this option as a Client default option to report
+// every request made by the client.
+func WithReport(r *Report) Option {
+	return optionFunc(func(o *options) error {
+		o.report = r
+		return nil
+	})
+}
+
+// WithName sets the name of the request made by the Request or Check function
+// that is used in reports.
+func WithName(name string) Option {
+	return optionFunc(func(o *options) error {
+		o.name = name
+		return nil
+	})
+}
+
+// Entries returns all reported results in the order in which requests were
+// completed.
+func (r *Report) Entries() []ReportEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entries := make([]ReportEntry, len(r.entries))
+	copy(entries, r.entries)
+	return entries
+}
+
+func (r *Report) add(e ReportEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.entries = append(r.entries, e)
+}
+
+// reportResult adds the result to the report set by the WithReport option.
+func (o *options) reportResult(name, method, url string, start time.Time, res *result) {
+	if o.report == nil {
+		return
+	}
+	if o.name != "" {
+		name = o.name
+	}
+	e := ReportEntry{
+		Name:     name,
+		Method:   method,
+		URL:      url,
+		Start:    start,
+		Duration: res.duration,
+		Attempts: res.attempts,
+		Failures: res.failures,
+		Timing:   res.exchange.timing,
+	}
+	if req := res.exchange.request; req != nil {
+		e.Method = req.Method
+		e.URL = req.URL.String()
+	}
+	if resp := res.exchange.response; resp != nil {
+		e.Status = resp.StatusCode
+	}
+	if res.err != nil {
+		e.Error = res.err.Error()
+	}
+	o.report.add(e)
+}
+
+type junitTestSuite struct {
+	XMLName  xml.Name        `xml:"testsuite"`
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitProblem `xml:"failure,omitempty"`
+	Error     *junitProblem `xml:"error,omitempty"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes all reported results as a JUnit XML test suite with a test
+// case for every request.
+func (r *Report) WriteJUnit(w io.Writer) error {
+	entries := r.Entries()
+	suite := junitTestSuite{
+		Name:  "httpapitest",
+		Tests: len(entries),
+	}
+	var total time.Duration
+	for _, e := range entries {
+		total += e.Duration
+		request := e.Method + " " + e.URL
+		name := e.Name
+		if name == "" {
+			name = request
+		}
+		c := junitTestCase{
+			Name:      name,
+			Classname: request,
+			Time:      seconds(e.Duration),
+		}
+		if len(e.Failures) > 0 {
+			messages := make([]string, 0, len(e.Failures))
+			for _, f := range e.Failures {
+				messages = append(messages, f.Message)
+			}
+			c.Failure = &junitProblem{
+				Message: e.Failures[0].Message,
+				Type:    e.Failures[0].Kind,
+				Text:    strings.Join(messages, "\n"),
+			}
+			suite.Failures++
+		}
+		if e.Error != "" {
+			c.Error = &junitProblem{
+				Message: e.Error,
+				Text:    e.Error,
+			}
+			suite.Errors++
+		}
+		suite.Cases = append(suite.Cases, c)
+	}
+	suite.Time = seconds(total)
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suite); err != nil {
+		return fmt.Errorf("xml encode report: %w", err)
+	}
+	buf.WriteString("\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+type jsonReport struct {
+	Tests      int               `json:"tests"`
+	Passed     int               `json:"passed"`
+	Failed     int               `json:"failed"`
+	DurationMS float64           `json:"durationMs"`
+	Requests   []jsonReportEntry `json:"requests"`
+}
+
+type jsonReportEntry struct {
+	Name       string                `json:"name,omitempty"`
+	Method     string                `json:"method"`
+	URL        string                `json:"url"`
+	Status     int                   `json:"status,omitempty"`
+	Start      time.Time             `json:"start"`
+	DurationMS float64               `json:"durationMs"`
+	Timing     jsonReportTiming      `json:"timing"`
+	Attempts   int                   `json:"attempts"`
+	Passed     bool                  `json:"passed"`
+	Failures   []jsonReportAssertion `json:"failures,omitempty"`
+	Error      string                `json:"error,omitempty"`
+}
+
+type jsonReportTiming struct {
+	DNSMS             float64 `json:"dnsMs"`
+	ConnectMS         float64 `json:"connectMs"`
+	TLSHandshakeMS    float64 `json:"tlsHandshakeMs"`
+	TimeToFirstByteMS float64 `json:"timeToFirstByteMs"`
+	BodyReadMS        float64 `json:"bodyReadMs"`
+	TotalMS           float64 `json:"totalMs"`
+	ConnectionReused  bool    `json:"connectionReused"`
+}
+
+type jsonReportAssertion struct {
+	Kind     string `json:"kind"`
+	Path     string `json:"path,omitempty"`
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty"`
+	Message  string `json:"message"`
+}
+
+// WriteJSON writes all reported results as a JSON document with the number of
+// passed and failed requests and the details of every request.
+func (r *Report) WriteJSON(w io.Writer) error {
+	entries := r.Entries()
+	report := jsonReport{
+		Tests:    len(entries),
+		Requests: make([]jsonReportEntry, 0, len(entries)),
+	}
+	var total time.Duration
+	for _, e := range entries {
+		total += e.Duration
+		if e.Passed() {
+			report.Passed++
+		} else {
+			report.Failed++
+		}
+		je := jsonReportEntry{
+			Name:       e.Name,
+			Method:     e.Method,
+			URL:        e.URL,
+			Status:     e.Status,
+			Start:      e.Start,
+			DurationMS: milliseconds(e.Duration),
+			Timing: jsonReportTiming{
+				DNSMS:             milliseconds(e.Timing.DNS),
+				ConnectMS:         milliseconds(e.Timing.Connect),
+				TLSHandshakeMS:    milliseconds(e.Timing.TLSHandshake),
+				TimeToFirstByteMS: milliseconds(e.Timing.TimeToFirstByte),
+				BodyReadMS:        milliseconds(e.Timing.BodyRead),
+				TotalMS:           milliseconds(e.Timing.Total),
+				ConnectionReused:  e.Timing.ConnectionReused,
+			},
+			Attempts: e.Attempts,
+			Passed:   e.Passed(),
+			Error:    e.Error,
+		}
+		for _, f := range e.Failures {
+			je.Failures = append(je.Failures, jsonReportAssertion(f))
+		}
+		report.Requests = append(report.Requests, je)
+	}
+	report.DurationMS = milliseconds(total)
+
+	data, err := json.MarshalIndent(report, "", "\t")
+	if err != nil {
+		return fmt.Errorf("json encode report: %w", err)
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// WriteFile writes the report to the file, creating its directory if needed.
+// The file is written in JSON format if it has the .json extension and in
+// JUnit XML format otherwise.
+func (r *Report) WriteFile(filename string) error {
+	var buf bytes.Buffer
+	var err error
+	if strings.EqualFold(filepath.Ext(filename), ".json") {
+		err = r.WriteJSON(&buf)
+	} else {
+		err = r.WriteJUnit(&buf)
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0o777); err != nil {
+		return err
+	}
+	return os.WriteFile(filename, buf.Bytes(), 0o666)
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
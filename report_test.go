@@ -0,0 +1,202 @@
+// Copyright (c) 2023, Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httpapitest_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"resenje.org/httpapitest"
+)
+
+func TestReport(t *testing.T) {
+
+	c, endpoint := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	var r httpapitest.Report
+	client := httpapitest.NewClient(c, httpapitest.WithReport(&r), httpapitest.ExpectStatus(http.StatusOK))
+
+	t.Run("get", func(t *testing.T) {
+		client.Request(t, http.MethodGet, endpoint+"/users")
+	})
+	if _, err := httpapitest.Check(c, http.MethodDelete, endpoint+"/missing",
+		httpapitest.WithReport(&r),
+		httpapitest.WithName("delete missing"),
+		httpapitest.ExpectStatus(http.StatusNoContent),
+	); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := httpapitest.Check(c, http.MethodGet, "unknown://localhost",
+		httpapitest.WithReport(&r),
+	); err == nil {
+		t.Fatal("got no error")
+	}
+
+	entries := r.Entries()
+	if len(entries) != 3 {
+		t.Fatalf("got %v entries, want 3", len(entries))
+	}
+	type entry struct {
+		Name, Method, URL string
+		Status, Attempts  int
+		Passed            bool
+		Failures          int
+	}
+	got := make([]entry, 0, len(entries))
+	for _, e := range entries {
+		got = append(got, entry{e.Name, e.Method, e.URL, e.Status, e.Attempts, e.Passed(), len(e.Failures)})
+	}
+	want := []entry{
+		{"TestReport/get", http.MethodGet, endpoint + "/users", http.StatusOK, 1, true, 0},
+		{"delete missing", http.MethodDelete, endpoint + "/missing", http.StatusNotFound, 1, false, 1},
+		{"", http.MethodGet, "unknown://localhost", 0, 1, false, 0},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got entries %+v, want %+v", got, want)
+	}
+
+	var buf bytes.Buffer
+	if err := r.WriteJUnit(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var suite struct {
+		Tests    int `xml:"tests,attr"`
+		Failures int `xml:"failures,attr"`
+		Errors   int `xml:"errors,attr"`
+		Cases    []struct {
+			Name    string `xml:"name,attr"`
+			Failure *struct {
+				Message string `xml:"message,attr"`
+				Type    string `xml:"type,attr"`
+			} `xml:"failure"`
+		} `xml:"testcase"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &suite); err != nil {
+		t.Fatal(err)
+	}
+	if suite.Tests != 3 || suite.Failures != 1 || suite.Errors != 1 {
+		t.Errorf("got tests %v, failures %v, errors %v", suite.Tests, suite.Failures, suite.Errors)
+	}
+	if name := suite.Cases[2].Name; name != "GET unknown://localhost" {
+		t.Errorf("got test case name %q", name)
+	}
+	if f := suite.Cases[1].Failure; f == nil || f.Type != "status" || f.Message != "got response status 404 Not Found, want 204 No Content" {
+		t.Errorf("got failure %+v", f)
+	}
+
+	buf.Reset()
+	if err := r.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var report struct {
+		Tests    int `json:"tests"`
+		Passed   int `json:"passed"`
+		Failed   int `json:"failed"`
+		Requests []struct {
+			Name     string `json:"name"`
+			Passed   bool   `json:"passed"`
+			Failures []struct {
+				Kind     string `json:"kind"`
+				Expected string `json:"expected"`
+				Actual   string `json:"actual"`
+			} `json:"failures"`
+			Error string `json:"error"`
+		} `json:"requests"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Tests != 3 || report.Passed != 1 || report.Failed != 2 {
+		t.Errorf("got tests %v, passed %v, failed %v", report.Tests, report.Passed, report.Failed)
+	}
+	if f := report.Requests[1].Failures; len(f) != 1 || f[0].Kind != "status" || f[0].Expected != "204 No Content" || f[0].Actual != "404 Not Found" {
+		t.Errorf("got failures %+v", f)
+	}
+	if e := report.Requests[2].Error; !strings.Contains(e, "unsupported protocol scheme") {
+		t.Errorf("got error %q", e)
+	}
+}
+
+func TestReport_invalidOptions(t *testing.T) {
+
+	var r httpapitest.Report
+	_, err := httpapitest.Check(http.DefaultClient, http.MethodGet, "http://localhost/users",
+		httpapitest.Eventually(0, time.Millisecond, 1),
+		httpapitest.WithReport(&r),
+		httpapitest.WithName("invalid"),
+	)
+	if err == nil || err.Error() != "eventually: timeout must be positive" {
+		t.Fatalf("got error %v", err)
+	}
+
+	assert(t, "", "eventually: timeout must be positive", func(m *mock) {
+		httpapitest.Request(m, http.DefaultClient, http.MethodPost, "http://localhost/users",
+			httpapitest.Eventually(0, time.Millisecond, 1),
+			httpapitest.WithReport(&r),
+		)
+	})
+	assert(t, "", "request json: response body is consumed by the ExpectedResponse option", func(m *mock) {
+		httpapitest.RequestJSON[map[string]string](m, http.DefaultClient, http.MethodGet, "http://localhost/users/1",
+			httpapitest.ExpectedResponse(strings.NewReader("{}")),
+			httpapitest.WithReport(&r),
+		)
+	})
+
+	type entry struct {
+		Name, Method, URL, Error string
+		Passed                   bool
+	}
+	var got []entry
+	for _, e := range r.Entries() {
+		got = append(got, entry{e.Name, e.Method, e.URL, e.Error, e.Passed()})
+	}
+	want := []entry{
+		{"invalid", http.MethodGet, "http://localhost/users", "eventually: timeout must be positive", false},
+		{"mock", http.MethodPost, "http://localhost/users", "eventually: timeout must be positive", false},
+		{"mock", http.MethodGet, "http://localhost/users/1", "request json: response body is consumed by the ExpectedResponse option", false},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got entries %+v, want %+v", got, want)
+	}
+}
+
+func TestNewReport(t *testing.T) {
+
+	dir := t.TempDir()
+	t.Setenv(httpapitest.ArtifactsDirEnv, dir)
+
+	c, endpoint := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	t.Run("sub", func(t *testing.T) {
+		r := httpapitest.NewReport(t, "")
+		rj := httpapitest.NewReport(t, "report.json")
+		httpapitest.Request(t, c, http.MethodGet, endpoint, httpapitest.WithReport(r))
+		httpapitest.Request(t, c, http.MethodGet, endpoint, httpapitest.WithReport(rj))
+	})
+
+	b, err := os.ReadFile(filepath.Join(dir, "TestNewReport_sub.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `<testsuite name="httpapitest" tests="1" failures="0" errors="0"`) {
+		t.Errorf("got report %s", b)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "report.json")); err != nil {
+		t.Error(err)
+	}
+}
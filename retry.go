@@ -0,0 +1,119 @@
+// Copyright (c) 2023, Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httpapitest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxRetryAfter limits the duration specified by the Retry-After response
+// header, not to make tests wait for a server that is not available for a long
+// time.
+const maxRetryAfter = 10 * time.Second
+
+type retryOptions struct {
+	attempts int
+	delay    time.Duration
+	statuses []int
+}
+
+// WithRetry makes the request made by the Request function again if it fails
+// with a connection error or if the response has one of the provided status
+// codes, before any response validation. The request is made at most the
+// provided number of attempts, waiting for the delay between them, or for the
+// duration specified by the Retry-After response header, if it is present, but
+// at most 10 seconds or the delay, whichever is longer. Retried attempts are
+// logged on failure. The timeout set with the WithTimeout
+// option includes all attempts.
+func WithRetry(attempts int, delay time.Duration, statuses ...int) Option {
+	return optionFunc(func(o *options) error {
+		if attempts < 1 {
+			return errors.New("retry: attempts must be at least one")
+		}
+		if delay < 0 {
+			return errors.New("retry: delay must not be negative")
+		}
+		o.retry = &retryOptions{
+			attempts: attempts,
+			delay:    delay,
+			statuses: statuses,
+		}
+		return nil
+	})
+}
+
+// wait returns the duration to wait before the next attempt and true if the
+// request should be made again after the attempt that returned the response or
+// the error.
+func (r *retryOptions) wait(ctx context.Context, attempt int, resp *http.Response, err error) (time.Duration, bool) {
+	if r == nil || attempt >= r.attempts || ctx.Err() != nil {
+		return 0, false
+	}
+	if err != nil {
+		return r.delay, true
+	}
+	for _, code := range r.statuses {
+		if resp.StatusCode == code {
+			if d, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+				return r.capRetryAfter(d), true
+			}
+			return r.delay, true
+		}
+	}
+	return 0, false
+}
+
+// capRetryAfter returns the duration from the Retry-After header limited to
+// maxRetryAfter or the delay, whichever is longer.
+func (r *retryOptions) capRetryAfter(d time.Duration) time.Duration {
+	max := maxRetryAfter
+	if r.delay > max {
+		max = r.delay
+	}
+	if d > max {
+		return max
+	}
+	return d
+}
+
+// retryAfter parses the value of the Retry-After header that is either a
+// number of seconds or an HTTP date.
+func retryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if s, err := strconv.Atoi(v); err == nil {
+		if s < 0 {
+			return 0, false
+		}
+		return time.Duration(s) * time.Second, true
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	d := time.Until(t)
+	if d < 0 {
+		d = 0
+	}
+	return d, true
+}
+
+func retryMessage(attempt int, resp *http.Response, err error, wait time.Duration) string {
+	if err != nil {
+		return fmt.Sprintf("retry: attempt %v: %v, retrying after %v", attempt, err, wait)
+	}
+	m := fmt.Sprintf("retry: attempt %v: got response status %s, retrying after %v", attempt, resp.Status, wait)
+	if d, ok := retryAfter(resp.Header.Get("Retry-After")); ok && d > wait {
+		m += fmt.Sprintf(", capped from Retry-After %v", d.Round(time.Second))
+	}
+	return m
+}
@@ -0,0 +1,155 @@
+// Copyright (c) 2023, Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httpapitest_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"resenje.org/httpapitest"
+)
+
+func TestWithRetry(t *testing.T) {
+
+	var calls int32
+	c, endpoint := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		if b, _ := io.ReadAll(r.Body); string(b) != "data" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if r.URL.Path == "/ready" && n > 2 {
+			fmt.Fprint(w, "ok")
+			return
+		}
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+
+	t.Run("recovered", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		assert(t, "", "", func(m *mock) {
+			// Retry-After header is honoured instead of the delay
+			httpapitest.Request(m, c, http.MethodPost, endpoint+"/ready",
+				httpapitest.WithRetry(5, time.Hour, http.StatusBadGateway, http.StatusServiceUnavailable),
+				httpapitest.WithRequestBody(strings.NewReader("data")),
+				httpapitest.ExpectStatus(http.StatusOK),
+				httpapitest.ExpectedResponse(strings.NewReader("ok")),
+			)
+		})
+		if calls != 3 {
+			t.Errorf("got %v attempts, want 3", calls)
+		}
+	})
+
+	t.Run("exhausted", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		var logs []string
+		assert(t, "got response status 503 Service Unavailable, want 200 OK", "", func(m *mock) {
+			defer func() { logs = withoutTiming(t, m.gotLogs) }()
+			httpapitest.Request(m, c, http.MethodPost, endpoint+"/unavailable",
+				httpapitest.WithRetry(3, time.Millisecond, http.StatusServiceUnavailable),
+				httpapitest.WithRequestBody(strings.NewReader("data")),
+				httpapitest.ExpectStatus(http.StatusOK),
+			)
+		})
+		if calls != 3 {
+			t.Errorf("got %v attempts, want 3", calls)
+		}
+		want := []string{
+			"retry: attempt 1: got response status 503 Service Unavailable, retrying after 0s",
+			"retry: attempt 2: got response status 503 Service Unavailable, retrying after 0s",
+		}
+		if fmt.Sprint(logs) != fmt.Sprint(want) {
+			t.Errorf("got logs %q, want %q", logs, want)
+		}
+	})
+
+	t.Run("status not retried", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		assert(t, "got response status 503 Service Unavailable, want 200 OK", "", func(m *mock) {
+			httpapitest.Request(m, c, http.MethodPost, endpoint+"/unavailable",
+				httpapitest.WithRetry(3, time.Millisecond, http.StatusBadGateway),
+				httpapitest.WithRequestBody(strings.NewReader("data")),
+				httpapitest.ExpectStatus(http.StatusOK),
+			)
+		})
+		if calls != 1 {
+			t.Errorf("got %v attempts, want 1", calls)
+		}
+	})
+}
+
+func TestWithRetry_connectionError(t *testing.T) {
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	endpoint := s.URL
+	s.Close()
+
+	var logs []string
+	_, err := httpapitest.Check(s.Client(), http.MethodGet, endpoint,
+		httpapitest.WithRetry(3, time.Millisecond),
+	)
+	if err == nil {
+		t.Fatal("got no error")
+	}
+
+	var fatal string
+	assert(t, "", err.Error(), func(m *mock) {
+		defer func() { logs = withoutTiming(t, m.gotLogs); fatal = m.gotFatal }()
+		httpapitest.Request(m, s.Client(), http.MethodGet, endpoint,
+			httpapitest.WithRetry(3, time.Millisecond),
+		)
+	})
+	if len(logs) != 2 {
+		t.Fatalf("got logs %q, want 2", logs)
+	}
+	for i, l := range logs {
+		want := fmt.Sprintf("retry: attempt %v: %s, retrying after 1ms", i+1, fatal)
+		if l != want {
+			t.Errorf("got log %q, want %q", l, want)
+		}
+	}
+}
+
+func TestWithRetry_invalid(t *testing.T) {
+
+	_, err := httpapitest.Check(http.DefaultClient, http.MethodGet, "http://localhost/",
+		httpapitest.WithRetry(0, time.Millisecond),
+	)
+	if err == nil || err.Error() != "retry: attempts must be at least one" {
+		t.Errorf("got error %v", err)
+	}
+}
+
+func TestWithRetry_retryAfterCapped(t *testing.T) {
+
+	c, endpoint := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+
+	var logs []string
+	assert(t, "", "context deadline exceeded", func(m *mock) {
+		defer func() { logs = withoutTiming(t, m.gotLogs) }()
+		httpapitest.Request(m, c, http.MethodGet, endpoint,
+			httpapitest.WithRetry(2, time.Millisecond, http.StatusServiceUnavailable),
+			httpapitest.WithTimeout(50*time.Millisecond),
+		)
+	})
+	want := []string{
+		"retry: attempt 1: got response status 503 Service Unavailable, retrying after 10s, capped from Retry-After 1h0m0s",
+	}
+	if fmt.Sprint(logs) != fmt.Sprint(want) {
+		t.Errorf("got logs %q, want %q", logs, want)
+	}
+}
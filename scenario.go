@@ -0,0 +1,141 @@
+// Copyright (c) 2023, Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httpapitest
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"text/tabwriter"
+	"time"
+)
+
+// Scenario groups a sequence of requests as named steps that are run as
+// subtests. After a step fails with a fatal error, all subsequent steps are
+// skipped. When the test is done, a summary of all steps is logged.
+//
+// Example:
+//
+//	s := httpapitest.NewScenario(t, client)
+//	s.Request("create user", http.MethodPost, url+"/users",
+//		httpapitest.WithJSONRequestBody(user),
+//		httpapitest.ExpectStatus(http.StatusCreated),
+//		httpapitest.CaptureJSONPath("id", "$.id"),
+//	)
+//	s.Request("get user", http.MethodGet, url+"/users/{{id}}",
+//		httpapitest.ExpectStatus(http.StatusOK),
+//	)
+type Scenario struct {
+	t       *testing.T
+	client  *http.Client
+	opts    []Option
+	vars    *Vars
+	steps   []scenarioStep
+	stopped string
+}
+
+type scenarioStep struct {
+	name     string
+	status   string
+	duration time.Duration
+}
+
+const (
+	stepPassed  = "ok"
+	stepFailed  = "FAIL"
+	stepStopped = "FATAL"
+	stepSkipped = "SKIP"
+)
+
+// NewScenario creates a new Scenario that makes requests with the provided
+// client and options applied to every request before the ones provided for the
+// step. Values captured in one step are interpolated into requests of the
+// subsequent steps, as with the WithVars option.
+func NewScenario(t *testing.T, client *http.Client, opts ...Option) *Scenario {
+	t.Helper()
+
+	s := &Scenario{
+		t:      t,
+		client: client,
+		vars:   new(Vars),
+	}
+	s.opts = append([]Option{WithVars(s.vars)}, opts...)
+	t.Cleanup(func() {
+		if len(s.steps) > 0 {
+			t.Logf("%s", s.Summary())
+		}
+	})
+	return s
+}
+
+// Vars returns the variables store shared by all scenario steps.
+func (s *Scenario) Vars() *Vars {
+	return s.vars
+}
+
+// Step runs the function as a subtest with the provided name. If any previous
+// step failed with a fatal error, the subtest is skipped. It returns true if
+// the step passed.
+func (s *Scenario) Step(name string, f func(t *testing.T)) bool {
+	s.t.Helper()
+
+	i := len(s.steps)
+	s.steps = append(s.steps, scenarioStep{name: name})
+	return s.t.Run(name, func(t *testing.T) {
+		t.Helper()
+
+		if s.stopped != "" {
+			s.steps[i].status = stepSkipped
+			t.Skipf("skipped after step %q failed", s.stopped)
+		}
+
+		start := time.Now()
+		var finished bool
+		defer func() {
+			s.steps[i].duration = time.Since(start)
+			switch {
+			case !t.Failed():
+				s.steps[i].status = stepPassed
+			case finished:
+				s.steps[i].status = stepFailed
+			default:
+				s.steps[i].status = stepStopped
+				s.stopped = name
+			}
+		}()
+		f(t)
+		finished = true
+	})
+}
+
+// Request runs a step that makes a request with the Request function, using
+// the scenario client and options. It returns true if the step passed.
+func (s *Scenario) Request(name, method, url string, opts ...Option) bool {
+	s.t.Helper()
+
+	return s.Step(name, func(t *testing.T) {
+		t.Helper()
+
+		Request(t, s.client, method, url, append(s.opts[:len(s.opts):len(s.opts)], opts...)...)
+	})
+}
+
+// Summary returns the status and duration of every step, one per line.
+func (s *Scenario) Summary() string {
+	var b strings.Builder
+	b.WriteString("scenario steps:\n")
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	for i, step := range s.steps {
+		if step.status == stepSkipped || step.status == "" {
+			fmt.Fprintf(w, "\t%v\t%s\t%s\n", i+1, stepSkipped, step.name)
+			continue
+		}
+		fmt.Fprintf(w, "\t%v\t%s\t%s\t%s\n", i+1, step.status, step.name, step.duration.Round(time.Microsecond))
+	}
+	_ = w.Flush()
+	return strings.TrimRight(b.String(), " \n")
+}
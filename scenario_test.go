@@ -0,0 +1,103 @@
+// Copyright (c) 2023, Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httpapitest_test
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"regexp"
+	"testing"
+
+	"resenje.org/httpapitest"
+)
+
+func TestScenario(t *testing.T) {
+
+	c, endpoint := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, `{"id":42}`)
+		case http.MethodGet:
+			if r.URL.Path != "/users/42" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			fmt.Fprint(w, `{"id":42}`)
+		}
+	}))
+
+	s := httpapitest.NewScenario(t, c, httpapitest.ExpectStatus(http.StatusOK))
+	if !s.Request("create user", http.MethodPost, endpoint+"/users",
+		httpapitest.ExpectStatus(http.StatusCreated),
+		httpapitest.CaptureJSONPath("id", "$.id"),
+	) {
+		t.Error("create user step failed")
+	}
+	if !s.Request("get user", http.MethodGet, endpoint+"/users/{{id}}",
+		httpapitest.ExpectJSONPath("$.id", 42),
+	) {
+		t.Error("get user step failed")
+	}
+	if !s.Step("check", func(t *testing.T) {
+		if id, _ := s.Vars().Get("id"); id != "42" {
+			t.Errorf("got id %q, want %q", id, "42")
+		}
+	}) {
+		t.Error("check step failed")
+	}
+
+	if !regexp.MustCompile(`^scenario steps:
+  1  ok  create user  \S+
+  2  ok  get user     \S+
+  3  ok  check        \S+$`).MatchString(s.Summary()) {
+		t.Errorf("got summary %q", s.Summary())
+	}
+}
+
+func TestScenario_failure(t *testing.T) {
+	if os.Getenv("HTTPAPITEST_SCENARIO_FAILURE") == "" {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestScenario_failure$", "-test.v")
+		cmd.Env = append(os.Environ(), "HTTPAPITEST_SCENARIO_FAILURE=1")
+		out, err := cmd.CombinedOutput()
+		if err == nil {
+			t.Fatalf("scenario did not fail:\n%s", out)
+		}
+		for _, want := range []string{
+			`--- PASS: TestScenario_failure/first`,
+			`--- FAIL: TestScenario_failure/second`,
+			`got response status 404 Not Found, want 200 OK`,
+			`--- FAIL: TestScenario_failure/third`,
+			`--- SKIP: TestScenario_failure/fourth`,
+			`skipped after step "third" failed`,
+			`scenario steps:`,
+			`  2  FAIL   second  `,
+			`  3  FATAL  third   `,
+			`  4  SKIP   fourth`,
+		} {
+			if !regexp.MustCompile(regexp.QuoteMeta(want)).Match(out) {
+				t.Errorf("output does not contain %q:\n%s", want, out)
+			}
+		}
+		return
+	}
+
+	c, endpoint := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	s := httpapitest.NewScenario(t, c, httpapitest.ExpectStatus(http.StatusOK))
+	s.Request("first", http.MethodGet, endpoint)
+	s.Request("second", http.MethodGet, endpoint+"/missing")
+	s.Step("third", func(t *testing.T) {
+		t.Fatal("stop")
+	})
+	s.Request("fourth", http.MethodGet, endpoint)
+}
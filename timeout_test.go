@@ -0,0 +1,78 @@
+// Copyright (c) 2023, Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httpapitest_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"resenje.org/httpapitest"
+)
+
+func TestWithTimeout(t *testing.T) {
+
+	c, endpoint := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+
+	_, err := httpapitest.Check(c, http.MethodGet, endpoint,
+		httpapitest.WithTimeout(10*time.Millisecond),
+	)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got error %v, want deadline exceeded", err)
+	}
+}
+
+func TestExpectMaxLatency(t *testing.T) {
+
+	c, endpoint := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow-headers" {
+			time.Sleep(50 * time.Millisecond)
+		}
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		if r.URL.Path == "/slow-body" {
+			time.Sleep(50 * time.Millisecond)
+		}
+		fmt.Fprint(w, "body")
+	}))
+
+	for _, tc := range []struct {
+		path  string
+		kinds []string
+	}{
+		{path: "/"},
+		{path: "/slow-body", kinds: []string{"total"}},
+		{path: "/slow-headers", kinds: []string{"time to first byte", "total"}},
+	} {
+		t.Run(tc.path, func(t *testing.T) {
+			failures, err := httpapitest.Check(c, http.MethodGet, endpoint+tc.path,
+				httpapitest.ExpectMaxTimeToFirstByte(30*time.Millisecond),
+				httpapitest.ExpectMaxLatency(30*time.Millisecond),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var kinds []string
+			for _, f := range failures {
+				if f.Kind != httpapitest.AssertionLatency || f.Expected != "30ms" {
+					t.Errorf("got failure %+v", f)
+				}
+				kinds = append(kinds, f.Path)
+			}
+			if fmt.Sprint(kinds) != fmt.Sprint(tc.kinds) {
+				t.Errorf("got failures %q, want %q", kinds, tc.kinds)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright (c) 2023, Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httpapitest
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Timing holds durations of phases of the request made by the Request
+// function. Phases that did not happen, such as DNS lookup for an IP address
+// or connecting when the connection is reused, have zero durations. Timing of
+// the request is logged if any validation fails.
+type Timing struct {
+	DNS             time.Duration
+	Connect         time.Duration
+	TLSHandshake    time.Duration
+	TimeToFirstByte time.Duration
+	BodyRead        time.Duration
+	Total           time.Duration
+	// ConnectionReused is true if the request was sent over a previously
+	// used connection.
+	ConnectionReused bool
+}
+
+func (t Timing) String() string {
+	s := fmt.Sprintf("dns %v, connect %v, tls handshake %v, time to first byte %v, body read %v, total %v",
+		t.DNS, t.Connect, t.TLSHandshake, t.TimeToFirstByte, t.BodyRead, t.Total)
+	if t.ConnectionReused {
+		s += ", connection reused"
+	}
+	return s
+}
+
+// PutTiming sets the timing of the request made by the Request function to the
+// provided value. Body read duration is measured from the first byte of the
+// response until the last read of the response body, as required by other
+// options.
+func PutTiming(t *Timing) Option {
+	return optionFunc(func(o *options) error {
+		o.timing = t
+		return nil
+	})
+}
+
+// timingTracer measures request phases with httptrace hooks, that may be
+// called from different goroutines.
+type timingTracer struct {
+	mu           sync.Mutex
+	start        time.Time
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+	firstByte    time.Time
+	headers      time.Time
+	lastRead     time.Time
+	timing       Timing
+}
+
+func newTimingTracer(start time.Time) *timingTracer {
+	return &timingTracer{start: start}
+}
+
+func (t *timingTracer) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.update(func() { t.dnsStart = time.Now() })
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.update(func() { t.timing.DNS = time.Since(t.dnsStart) })
+		},
+		ConnectStart: func(string, string) {
+			t.update(func() { t.connectStart = time.Now() })
+		},
+		ConnectDone: func(string, string, error) {
+			t.update(func() { t.timing.Connect = time.Since(t.connectStart) })
+		},
+		TLSHandshakeStart: func() {
+			t.update(func() { t.tlsStart = time.Now() })
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.update(func() { t.timing.TLSHandshake = time.Since(t.tlsStart) })
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.update(func() { t.timing.ConnectionReused = info.Reused })
+		},
+		GotFirstResponseByte: func() {
+			t.update(func() {
+				t.firstByte = time.Now()
+				t.timing.TimeToFirstByte = t.firstByte.Sub(t.start)
+			})
+		},
+	}
+}
+
+func (t *timingTracer) update(f func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	f()
+}
+
+// gotHeaders marks the time when the response headers are received.
+func (t *timingTracer) gotHeaders() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.headers = time.Now()
+	t.lastRead = t.headers
+	if t.firstByte.IsZero() {
+		t.firstByte = t.headers
+		t.timing.TimeToFirstByte = t.headers.Sub(t.start)
+	}
+}
+
+// body wraps the response body to record the time of its last read.
+func (t *timingTracer) body(r io.ReadCloser) io.ReadCloser {
+	return &timedBody{ReadCloser: r, t: t}
+}
+
+// result returns the timing measured until the last response body read.
+func (t *timingTracer) result() Timing {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	timing := t.timing
+	if !t.headers.IsZero() {
+		timing.BodyRead = t.lastRead.Sub(t.firstByte)
+		timing.Total = t.lastRead.Sub(t.start)
+	} else {
+		timing.Total = time.Since(t.start)
+	}
+	return timing
+}
+
+type timedBody struct {
+	io.ReadCloser
+	t *timingTracer
+}
+
+func (b *timedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.t.update(func() { b.t.lastRead = time.Now() })
+	return n, err
+}
@@ -0,0 +1,72 @@
+// Copyright (c) 2023, Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httpapitest_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"resenje.org/httpapitest"
+)
+
+func TestPutTiming(t *testing.T) {
+
+	c, endpoint := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		time.Sleep(20 * time.Millisecond)
+		fmt.Fprint(w, "body")
+	}))
+
+	var timing httpapitest.Timing
+	httpapitest.Request(t, c, http.MethodGet, endpoint,
+		httpapitest.PutTiming(&timing),
+		httpapitest.PutResponseBody(new([]byte)),
+	)
+	if timing.Connect <= 0 {
+		t.Errorf("got connect duration %v", timing.Connect)
+	}
+	if timing.ConnectionReused {
+		t.Error("got reused connection")
+	}
+	if timing.TimeToFirstByte <= 0 || timing.TimeToFirstByte >= 20*time.Millisecond {
+		t.Errorf("got time to first byte %v", timing.TimeToFirstByte)
+	}
+	if timing.BodyRead <= 0 {
+		t.Errorf("got body read duration %v", timing.BodyRead)
+	}
+	if timing.Total < 20*time.Millisecond || timing.Total < timing.TimeToFirstByte+timing.BodyRead {
+		t.Errorf("got total duration %v", timing.Total)
+	}
+
+	httpapitest.Request(t, c, http.MethodGet, endpoint,
+		httpapitest.PutTiming(&timing),
+		httpapitest.PutResponseBody(new([]byte)),
+	)
+	if !timing.ConnectionReused {
+		t.Error("got new connection")
+	}
+	if timing.Connect != 0 {
+		t.Errorf("got connect duration %v", timing.Connect)
+	}
+}
+
+func TestPutTiming_tls(t *testing.T) {
+
+	s := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer s.Close()
+
+	var timing httpapitest.Timing
+	httpapitest.Request(t, s.Client(), http.MethodGet, s.URL,
+		httpapitest.PutTiming(&timing),
+	)
+	if timing.TLSHandshake <= 0 {
+		t.Errorf("got tls handshake duration %v", timing.TLSHandshake)
+	}
+}
@@ -0,0 +1,222 @@
+// Copyright (c) 2023, Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httpapitest
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sync"
+)
+
+// varReference matches {{name}} variable references.
+var varReference = regexp.MustCompile(`{{\s*([^{}\s]+)\s*}}`)
+
+// Vars is a store of named string values that are captured from responses and
+// interpolated into requests made by the Request function. The zero value is
+// an empty store ready to use. It is safe for concurrent use.
+type Vars struct {
+	values map[string]string
+	mu     sync.RWMutex
+}
+
+// Set sets the value of the variable.
+func (v *Vars) Set(name, value string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.values == nil {
+		v.values = make(map[string]string)
+	}
+	v.values[name] = value
+}
+
+// Get returns the value of the variable and whether it is set.
+func (v *Vars) Get(name string) (string, bool) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	value, ok := v.values[name]
+	return value, ok
+}
+
+// Expand replaces {{name}} references in the string with variable values. It
+// returns an error if any referenced variable is not set.
+func (v *Vars) Expand(s string) (string, error) {
+	var err error
+	s = varReference.ReplaceAllStringFunc(s, func(m string) string {
+		name := varReference.FindStringSubmatch(m)[1]
+		value, ok := v.Get(name)
+		if !ok && err == nil {
+			err = fmt.Errorf("undefined variable %q", name)
+		}
+		return value
+	})
+	return s, err
+}
+
+// WithVars interpolates {{name}} variable references in the URL, header
+// values and the body of the request made by the Request function with values
+// from the store. Capture options store values in it.
+func WithVars(v *Vars) Option {
+	return optionFunc(func(o *options) error {
+		o.vars = v
+		return nil
+	})
+}
+
+type captureSource int
+
+const (
+	captureJSONPath captureSource = iota
+	captureHeader
+	captureCookie
+)
+
+// capturesJSONPath returns true if any variable is captured from the JSON
+// response body.
+func (o *options) capturesJSONPath() bool {
+	for _, c := range o.captures {
+		if c.source == captureJSONPath {
+			return true
+		}
+	}
+	return false
+}
+
+type capture struct {
+	name   string
+	source captureSource
+	key    string
+	path   *jsonPath
+}
+
+// CaptureJSONPath sets the variable in the store provided with the WithVars
+// option to the value selected by the JSONPath expression from the JSON
+// response body of the request in the Request function. Strings are stored
+// as they are and other values as JSON. Expressions are the same as in the
+// ExpectJSONPath option.
+func CaptureJSONPath(name, expression string) Option {
+	return optionFunc(func(o *options) error {
+		p, err := parseJSONPath(expression)
+		if err != nil {
+			return err
+		}
+		o.captures = append(o.captures, capture{name: name, source: captureJSONPath, key: expression, path: p})
+		return nil
+	})
+}
+
+// CaptureHeader sets the variable in the store provided with the WithVars
+// option to the value of the response header of the request in the Request
+// function.
+func CaptureHeader(name, header string) Option {
+	return optionFunc(func(o *options) error {
+		o.captures = append(o.captures, capture{name: name, source: captureHeader, key: header})
+		return nil
+	})
+}
+
+// CaptureCookie sets the variable in the store provided with the WithVars
+// option to the value of the cookie set by the response of the request in the
+// Request function.
+func CaptureCookie(name, cookie string) Option {
+	return optionFunc(func(o *options) error {
+		o.captures = append(o.captures, capture{name: name, source: captureCookie, key: cookie})
+		return nil
+	})
+}
+
+var errMissingVars = errors.New("capture variables: missing WithVars option")
+
+// expandRequest interpolates variables in the request url, headers and body.
+func (o *options) expandRequest(url string, body []byte) (string, http.Header, []byte, error) {
+	url, err := o.vars.Expand(url)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("request url: %w", err)
+	}
+	var header http.Header
+	if o.requestHeaders != nil {
+		header = make(http.Header, len(o.requestHeaders))
+		for k, values := range o.requestHeaders {
+			for _, v := range values {
+				v, err := o.vars.Expand(v)
+				if err != nil {
+					return "", nil, nil, fmt.Errorf("request header %q: %w", k, err)
+				}
+				header[k] = append(header[k], v)
+			}
+		}
+	}
+	if body != nil {
+		s, err := o.vars.Expand(string(body))
+		if err != nil {
+			return "", nil, nil, fmt.Errorf("request body: %w", err)
+		}
+		body = []byte(s)
+	}
+	return url, header, body, nil
+}
+
+// captureVars stores captured values from the response in the variables
+// store.
+func (o *options) captureVars(resp *http.Response, body []byte) []AssertionError {
+	var failures []AssertionError
+	fail := func(c capture, format string, a ...interface{}) {
+		failures = append(failures, AssertionError{
+			Kind:    AssertionCapture,
+			Path:    c.key,
+			Message: fmt.Sprintf("capture %q: ", c.name) + fmt.Sprintf(format, a...),
+		})
+	}
+	var got interface{}
+	var decodeErr error
+	var decoded bool
+	for _, c := range o.captures {
+		switch c.source {
+		case captureJSONPath:
+			if !decoded {
+				got, decodeErr = decodeJSON(body)
+				decoded = true
+			}
+			if decodeErr != nil {
+				fail(c, "got invalid json response %q: %v", string(body), decodeErr)
+				continue
+			}
+			v, ok := c.path.value(got)
+			if !ok {
+				fail(c, "got no json value at %q", c.key)
+				continue
+			}
+			if s, ok := v.(string); ok {
+				o.vars.Set(c.name, s)
+			} else {
+				o.vars.Set(c.name, jsonString(v))
+			}
+		case captureHeader:
+			values := resp.Header.Values(c.key)
+			if len(values) == 0 {
+				fail(c, "got no header %q", c.key)
+				continue
+			}
+			o.vars.Set(c.name, values[0])
+		case captureCookie:
+			var found bool
+			for _, cookie := range resp.Cookies() {
+				if cookie.Name == c.key {
+					o.vars.Set(c.name, cookie.Value)
+					found = true
+					break
+				}
+			}
+			if !found {
+				fail(c, "got no cookie %q", c.key)
+			}
+		}
+	}
+	return failures
+}
@@ -0,0 +1,114 @@
+// Copyright (c) 2023, Janoš Guljaš <janos@resenje.org>
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httpapitest_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"resenje.org/httpapitest"
+)
+
+func TestVars(t *testing.T) {
+
+	var got []string
+	c, endpoint := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		got = append(got, r.Method+" "+r.URL.Path+" "+r.Header.Get("X-Session")+" "+string(b))
+		switch r.Method {
+		case http.MethodPost:
+			w.Header().Set("Location", "/users/42")
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc"})
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, `{"id":42,"name":"test","tags":["a","b"]}`)
+		default:
+			fmt.Fprint(w, `{"id":42}`)
+		}
+	}))
+
+	vars := new(httpapitest.Vars)
+	vars.Set("name", "test")
+
+	assert(t, "", "", func(m *mock) {
+		httpapitest.Request(m, c, http.MethodPost, endpoint+"/users",
+			httpapitest.WithVars(vars),
+			httpapitest.WithRequestBody(strings.NewReader(`{"name":"{{name}}"}`)),
+			httpapitest.CaptureJSONPath("id", "$.id"),
+			httpapitest.CaptureJSONPath("userName", "$.name"),
+			httpapitest.CaptureJSONPath("tags", "$.tags"),
+			httpapitest.CaptureHeader("location", "Location"),
+			httpapitest.CaptureCookie("session", "session"),
+			httpapitest.ExpectStatus(http.StatusCreated),
+		)
+	})
+
+	for name, want := range map[string]string{
+		"id":       "42",
+		"userName": "test",
+		"tags":     `["a","b"]`,
+		"location": "/users/42",
+		"session":  "abc",
+	} {
+		if v, ok := vars.Get(name); !ok || v != want {
+			t.Errorf("got variable %q value %q, want %q", name, v, want)
+		}
+	}
+
+	assert(t, "", "", func(m *mock) {
+		httpapitest.Request(m, c, http.MethodGet, endpoint+"{{location}}",
+			httpapitest.WithVars(vars),
+			httpapitest.WithRequestHeader("X-Session", "{{ session }}"),
+			httpapitest.ExpectJSONPath("$.id", 42),
+		)
+	})
+
+	want := []string{
+		`POST /users  {"name":"test"}`,
+		"GET /users/42 abc ",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got requests %q, want %q", got, want)
+	}
+
+	if v, err := vars.Expand("{{id}}-{{missing}}"); err == nil || err.Error() != `undefined variable "missing"` {
+		t.Errorf("got expanded %q, error %v", v, err)
+	}
+
+	assert(t, "", `request url: undefined variable "missing"`, func(m *mock) {
+		httpapitest.Request(m, c, http.MethodGet, endpoint+"/{{missing}}",
+			httpapitest.WithVars(vars),
+		)
+	})
+
+	assert(t, "", "capture variables: missing WithVars option", func(m *mock) {
+		httpapitest.Request(m, c, http.MethodGet, endpoint,
+			httpapitest.CaptureJSONPath("id", "$.id"),
+		)
+	})
+
+	var m *mock
+	assert(t, `capture "session": got no cookie "session"`, "", func(mm *mock) {
+		m = mm
+		httpapitest.Request(m, c, http.MethodGet, endpoint,
+			httpapitest.WithVars(vars),
+			httpapitest.CaptureJSONPath("name", "$.name"),
+			httpapitest.CaptureHeader("location", "Location"),
+			httpapitest.CaptureCookie("session", "session"),
+		)
+	})
+	wantErrors := []string{
+		`capture "name": got no json value at "$.name"`,
+		`capture "location": got no header "Location"`,
+		`capture "session": got no cookie "session"`,
+	}
+	if !reflect.DeepEqual(m.gotErrors, wantErrors) {
+		t.Errorf("got errors %q, want %q", m.gotErrors, wantErrors)
+	}
+}